@@ -0,0 +1,182 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/jace-ys/konduit/internal/argocd"
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+type ArgoCDCmd struct {
+	Init     ArgoCDInitCmd     `cmd:"" help:"Prepare the application source, such as building chart dependencies."`
+	Generate ArgoCDGenerateCmd `cmd:"" help:"Generate manifests for the application source."`
+	Discover ArgoCDDiscoverCmd `cmd:"" help:"Detect whether the application source should be handled by Konduit."`
+}
+
+type ArgoCDInitCmd struct {
+	HelmCommand string `default:"helm" help:"Helm command or path to an executable."`
+}
+
+func (c *ArgoCDInitCmd) Run(ctx context.Context, g *Globals) error {
+	env, err := argocd.FromEnviron(os.Environ())
+	if err != nil {
+		return fmt.Errorf("read environment: %w", err)
+	}
+
+	chart := env.String("chart", "KONDUIT_CHART")
+	if chart == "" {
+		chart = "."
+	}
+
+	if _, err := os.Stat(filepath.Join(chart, "Chart.yaml")); err != nil {
+		return nil
+	}
+
+	runner, err := g.Runner()
+	if err != nil {
+		return err
+	}
+
+	if err := runner.Run(ctx, c.HelmCommand, []string{"dependency", "build", chart}, exec.WithStdout(g.Stderr)); err != nil {
+		return fmt.Errorf("build chart dependencies: %w", err)
+	}
+
+	return nil
+}
+
+type ArgoCDGenerateCmd struct {
+	HelmCommand string `help:"Helm command or path to an executable."`
+
+	NoCache  bool   `env:"KONDUIT_NO_CACHE" help:"Disable caching of CUE evaluation results."`
+	CacheDir string `env:"KONDUIT_CACHE_DIR" help:"Directory to cache CUE evaluation results in. If empty, the user cache directory is used."`
+}
+
+func (c *ArgoCDGenerateCmd) Run(ctx context.Context, g *Globals) error {
+	env, err := argocd.FromEnviron(os.Environ())
+	if err != nil {
+		return fmt.Errorf("read environment: %w", err)
+	}
+
+	runner, err := g.Runner()
+	if err != nil {
+		return err
+	}
+
+	k, err := c.instance(env, runner, g)
+	if err != nil {
+		return err
+	}
+
+	if err := k.Execute(ctx); err != nil {
+		return fmt.Errorf("execute: %w", err)
+	}
+
+	return nil
+}
+
+// instance maps the parameters of the application onto the Helm arguments,
+// values, patches and scopes of a Konduit instance.
+func (c *ArgoCDGenerateCmd) instance(env *argocd.Environment, runner konduit.Runner, g *Globals) (*konduit.Instance, error) {
+	scope, err := json.Marshal(env.Scope())
+	if err != nil {
+		return nil, fmt.Errorf("encode argocd scope: %w", err)
+	}
+	scopes := append([]string{string(scope)}, env.Array("scopes", "KONDUIT_SCOPES", "\n")...)
+
+	flags := CUEFlags{
+		Scopes:        scopes,
+		CUEBaseDir:    env.String("cue-base-dir", "KONDUIT_CUE_BASE_DIR"),
+		CUEModuleRoot: env.String("cue-module-root", "KONDUIT_CUE_MODULE_ROOT"),
+		NoCache:       c.NoCache,
+		CacheDir:      c.CacheDir,
+	}
+
+	opts := []konduit.Option{
+		konduit.WithEvaluator(flags.Evaluator(g.Log.Logger)),
+		konduit.WithRunner(runner),
+		konduit.WithStdout(g.Stdout),
+		konduit.WithLogger(g.Log.Logger),
+	}
+
+	if patches := env.Array("patches", "KONDUIT_PATCHES", ","); len(patches) > 0 {
+		opts = append(opts, konduit.WithPatches(patches))
+	}
+
+	if c.HelmCommand != "" {
+		opts = append(opts, konduit.WithHelmCommand(c.HelmCommand))
+	}
+
+	k, err := konduit.New(generateArgs(env), env.Array("values", "KONDUIT_VALUES", ","), opts...)
+	if err != nil {
+		return nil, &usageError{fmt.Errorf("init: %w", err)}
+	}
+
+	return k, nil
+}
+
+func generateArgs(env *argocd.Environment) []string {
+	chart := env.String("chart", "KONDUIT_CHART")
+	if chart == "" {
+		chart = "."
+	}
+
+	args := []string{"template", env.AppName, chart}
+
+	if env.AppNamespace != "" {
+		args = append(args, "--namespace", env.AppNamespace)
+	}
+
+	if env.KubeVersion != "" {
+		args = append(args, "--kube-version", env.KubeVersion)
+	}
+
+	for _, version := range env.KubeAPIVersions {
+		args = append(args, "--api-versions", version)
+	}
+
+	return append(args, env.Array("helm-args", "KONDUIT_HELM_ARGS", " ")...)
+}
+
+type ArgoCDDiscoverCmd struct{}
+
+func (c *ArgoCDDiscoverCmd) Run(ctx context.Context, g *Globals) error {
+	env, err := argocd.FromEnviron(os.Environ())
+	if err != nil {
+		return fmt.Errorf("read environment: %w", err)
+	}
+
+	match, err := discover(env, ".")
+	if err != nil {
+		return err
+	}
+
+	if match != "" {
+		fmt.Fprintln(g.Stdout, match)
+	}
+
+	return nil
+}
+
+// discover returns what Argo CD should print to use Konduit for the
+// application source in dir, or an empty string if Konduit doesn't apply.
+func discover(env *argocd.Environment, dir string) (string, error) {
+	if len(env.Array("values", "KONDUIT_VALUES", ",")) > 0 {
+		return "konduit", nil
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, "*.cue"))
+	if err != nil {
+		return "", fmt.Errorf("find CUE files: %w", err)
+	}
+
+	if len(matches) > 0 {
+		return filepath.Base(matches[0]), nil
+	}
+
+	return "", nil
+}
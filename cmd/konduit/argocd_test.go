@@ -0,0 +1,192 @@
+package main
+
+import (
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/argocd"
+	"github.com/jace-ys/konduit/pkg/konduit/mocks"
+)
+
+func mustEnviron(t *testing.T, environ ...string) *argocd.Environment {
+	t.Helper()
+
+	env, err := argocd.FromEnviron(environ)
+	require.NoError(t, err)
+	return env
+}
+
+func TestGenerateArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		environ []string
+		want    []string
+	}{
+		{
+			name:    "templates the chart in the source path by default",
+			environ: []string{"ARGOCD_APP_NAME=web"},
+			want:    []string{"template", "web", "."},
+		},
+		{
+			name: "passes the namespace and cluster capabilities",
+			environ: []string{
+				"ARGOCD_APP_NAME=web",
+				"ARGOCD_APP_NAMESPACE=apps",
+				"KUBE_VERSION=1.31",
+				"KUBE_API_VERSIONS=v1,apps/v1",
+			},
+			want: []string{
+				"template", "web", ".",
+				"--namespace", "apps",
+				"--kube-version", "1.31",
+				"--api-versions", "v1",
+				"--api-versions", "apps/v1",
+			},
+		},
+		{
+			name: "reads the chart and Helm args from env",
+			environ: []string{
+				"ARGOCD_APP_NAME=web",
+				"ARGOCD_ENV_KONDUIT_CHART=./charts/web",
+				"ARGOCD_ENV_KONDUIT_HELM_ARGS=--include-crds  --skip-tests",
+			},
+			want: []string{"template", "web", "./charts/web", "--include-crds", "--skip-tests"},
+		},
+		{
+			name: "prefers parameters over env",
+			environ: []string{
+				"ARGOCD_APP_NAME=web",
+				"ARGOCD_ENV_KONDUIT_CHART=./charts/web",
+				"ARGOCD_ENV_KONDUIT_HELM_ARGS=--skip-tests",
+				`ARGOCD_APP_PARAMETERS=[{"name":"chart","string":"oci://example.com/web"},{"name":"helm-args","array":["--version","1.2.3"]}]`,
+			},
+			want: []string{"template", "web", "oci://example.com/web", "--version", "1.2.3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, generateArgs(mustEnviron(t, tt.environ...)))
+		})
+	}
+}
+
+func TestArgoCDGenerateCmd_Instance(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	values := filepath.Join(dir, "values.cue")
+	static := filepath.Join(dir, "static.yaml")
+	patches := filepath.Join(dir, "patches.cue")
+	require.NoError(t, os.WriteFile(values, []byte("name: #Konduit.argocd.app.name\nnamespace: #Konduit.argocd.app.namespace\nenv: #Konduit.env\n"), 0o644))
+	require.NoError(t, os.WriteFile(static, []byte("replicas: 1\n"), 0o644))
+	require.NoError(t, os.WriteFile(patches, []byte("namePrefix: #Konduit.env + \"-\"\n"), 0o644))
+
+	tests := []struct {
+		name        string
+		environ     []string
+		helmCommand string
+		wantCommand string
+		wantValues  []string
+	}{
+		{
+			name: "maps env onto values, patches and scopes",
+			environ: []string{
+				"ARGOCD_ENV_KONDUIT_VALUES=" + values + "," + static,
+				"ARGOCD_ENV_KONDUIT_PATCHES=" + patches,
+				"ARGOCD_ENV_KONDUIT_SCOPES=env: production",
+				"ARGOCD_ENV_KONDUIT_CUE_BASE_DIR=" + dir,
+			},
+			wantCommand: "helm",
+			wantValues:  []string{static},
+		},
+		{
+			name: "maps parameters onto values, patches and scopes",
+			environ: []string{
+				"ARGOCD_ENV_KONDUIT_VALUES=" + static,
+				`ARGOCD_APP_PARAMETERS=[{"name":"values","array":["` + values + `"]},{"name":"patches","array":["` + patches + `"]},{"name":"scopes","array":["env: production"]},{"name":"cue-base-dir","string":"` + dir + `"}]`,
+			},
+			helmCommand: "helm3",
+			wantCommand: "helm3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			env := mustEnviron(t, append(tt.environ, "ARGOCD_APP_NAME=web", "ARGOCD_APP_NAMESPACE=apps")...)
+			g := &Globals{Log: Log{Logger: slog.New(slog.DiscardHandler)}}
+			cmd := &ArgoCDGenerateCmd{HelmCommand: tt.helmCommand, NoCache: true}
+
+			k, err := cmd.instance(env, mocks.NewMockRunner(t), g)
+			require.NoError(t, err)
+
+			inv, err := k.Construct(t.Context())
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.wantCommand, inv.Command)
+			assert.Equal(t, []string{"template", "web", ".", "--namespace", "apps"}, inv.Args[:5])
+			assert.Equal(t, tt.wantValues, inv.Values)
+			assert.Equal(t, []string{values}, inv.EvaluatedValues.Files)
+			assert.Equal(t, "name: web\nnamespace: apps\nenv: production\n", string(inv.EvaluatedValues.Raw()))
+			assert.Equal(t, []string{patches}, inv.EvaluatedPatches.Files)
+			assert.Equal(t, "namePrefix: production-\n", string(inv.EvaluatedPatches.Raw()))
+		})
+	}
+}
+
+func TestDiscover(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		environ []string
+		files   []string
+		want    string
+	}{
+		{
+			name:    "detects values set in env",
+			environ: []string{"ARGOCD_ENV_KONDUIT_VALUES=values.cue"},
+			want:    "konduit",
+		},
+		{
+			name:    "detects values set as parameters",
+			environ: []string{`ARGOCD_APP_PARAMETERS=[{"name":"values","array":["values.cue"]}]`},
+			want:    "konduit",
+		},
+		{
+			name:  "detects CUE files in the source",
+			files: []string{"Chart.yaml", "values.cue"},
+			want:  "values.cue",
+		},
+		{
+			name:  "ignores sources without CUE files",
+			files: []string{"Chart.yaml", "values.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for _, file := range tt.files {
+				require.NoError(t, os.WriteFile(filepath.Join(dir, file), nil, 0o644))
+			}
+
+			match, err := discover(mustEnviron(t, tt.environ...), dir)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, match)
+		})
+	}
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jace-ys/konduit/internal/cache"
+)
+
+type CacheCmd struct {
+	Prune CachePruneCmd `cmd:"" help:"Remove cached CUE evaluation results."`
+}
+
+type CachePruneCmd struct {
+	CacheDir  string        `env:"KONDUIT_CACHE_DIR" help:"Directory that CUE evaluation results are cached in. If empty, the user cache directory is used."`
+	OlderThan time.Duration `help:"Only remove results that have not been used for this long. If zero, all results are removed."`
+}
+
+func (c *CachePruneCmd) Run(ctx context.Context, g *Globals) error {
+	dir, err := cacheDir(c.CacheDir)
+	if err != nil {
+		return err
+	}
+
+	removed, err := cache.New(dir).Prune(c.OlderThan)
+	if err != nil {
+		return fmt.Errorf("prune cache: %w", err)
+	}
+
+	fmt.Fprintf(g.Stdout, "Removed %d cached results from %s\n", removed, dir)
+	return nil
+}
@@ -2,69 +2,137 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"path/filepath"
 
-	"github.com/jace-ys/konduit/pkg/cueval"
 	"github.com/jace-ys/konduit/pkg/konduit"
 )
 
 type CUECmd struct {
-	Show bool `help:"Print the resulting Helm invocation, with evaluated values and patches."`
+	Show ShowFormat `help:"Print the resulting Helm or Timoni invocation, with evaluated values and patches, instead of running it. Prints JSON, or YAML or a runnable shell script with --show=yaml or --show=shell."`
 
 	Values  []string `short:"v" help:"Helm values files to be evaluated by CUE."`
 	Patches []string `short:"p" help:"Kustomize patches files to be evaluated by CUE."`
-	Scopes  []string `short:"s" sep:"none" help:"JSON/YAML data (or @filename) to inject under the #Konduit definition."`
 
-	Args        []string `arg:"" passthrough:"partial" help:"Arguments after the leading -- are passed through to Helm."`
-	HelmCommand string   `help:"Helm command or path to an executable."`
+	CUEFlags `embed:""`
 
-	CUEBaseDir    string `help:"Base directory for import path resolution. If empty, the current directory is used."`
-	CUEModuleRoot string `help:"Directory that contains the cue.mod directory and packages."`
+	Args          []string `arg:"" passthrough:"partial" help:"Arguments after the leading -- are passed through to Helm, or to Timoni if prefixed with timoni."`
+	HelmCommand   string   `help:"Helm command or path to an executable."`
+	HelmVersion   int      `help:"Major version of Helm to target (3 or 4). If unset, it is detected from the Helm command."`
+	TimoniCommand string   `help:"Timoni command or path to an executable."`
+
+	PostRenderBefore []string `sep:"none" help:"Post-renderer to pipe the manifests through before patches are applied, as a command line such as 'helm-secrets post-render'. Repeat to chain several, in order."`
+	PostRenderAfter  []string `sep:"none" help:"Post-renderer to pipe the manifests through after patches are applied, and after any --post-renderer passed to Helm. Repeat to chain several, in order."`
 
 	Strict bool `help:"Disallow using evaluated and static configuration at the same time."`
+
+	InProcess bool `help:"Render templates in-process with the Helm SDK instead of running Helm (template command only)."`
+
+	WorkDir     string `type:"path" help:"Directory to write evaluated values, patches and intermediate manifests to, which is kept along with a description of each file."`
+	KeepWorkDir bool   `help:"Keep the temporary work dir, with intermediate manifests and a description of each file, for debugging."`
+
+	Watch bool `short:"w" help:"Render again whenever values, patches or the CUE files they load change, printing a diff of the manifests (template and build commands only)."`
 }
 
 func (c *CUECmd) Run(ctx context.Context, g *Globals) error {
 	if c.Args[0] != "--" {
-		return errors.New("must use -- to pass through Helm arguments")
+		return &usageError{errors.New("must use -- to pass through Helm or Timoni arguments")}
 	}
 
-	eval := konduit.NewCUEEvaluator(
-		cueval.WithScopes(c.Scopes...),
-		cueval.WithLoadDir(c.CUEBaseDir),
-		cueval.WithLoadModuleRoot(c.CUEModuleRoot),
-	)
+	runner, err := g.Runner()
+	if err != nil {
+		return err
+	}
 
 	opts := []konduit.Option{
-		konduit.WithEvaluator(eval),
+		konduit.WithEvaluator(c.Evaluator(g.Log.Logger)),
+		konduit.WithEvalTimeout(c.EvalTimeout),
+		konduit.WithRunner(runner),
+		konduit.WithStdout(g.Stdout),
 		konduit.WithModeStrict(c.Strict),
+		konduit.WithLogger(g.Log.Logger),
+	}
+
+	if c.WorkDir != "" {
+		opts = append(opts, konduit.WithWorkDir(c.WorkDir), konduit.WithKeepWorkDir(true))
+	} else if c.KeepWorkDir {
+		opts = append(opts, konduit.WithKeepWorkDir(true))
 	}
 
 	if len(c.Patches) > 0 {
 		opts = append(opts, konduit.WithPatches(c.Patches))
 	}
 
-	if c.HelmCommand != "" {
-		opts = append(opts, konduit.WithHelmCommand(c.HelmCommand))
+	before, err := parsePostRenderers(c.PostRenderBefore)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	after, err := parsePostRenderers(c.PostRenderAfter)
+	if err != nil {
+		return &usageError{err}
+	}
+
+	opts = append(opts, konduit.WithPostRenderersBefore(before...), konduit.WithPostRenderersAfter(after...))
+
+	args := c.Args[1:]
+	renderCommand := "template"
+
+	if len(args) > 0 && args[0] == konduit.DefaultTimoniCommand {
+		args = args[1:]
+		renderCommand = "build"
+		opts = append(opts, konduit.WithEngine(konduit.NewTimoniEngine()))
+
+		if c.TimoniCommand != "" {
+			opts = append(opts, konduit.WithCommand(c.TimoniCommand))
+		}
+	} else {
+		if c.HelmCommand != "" {
+			opts = append(opts, konduit.WithHelmCommand(c.HelmCommand))
+		}
+
+		if c.HelmVersion != 0 {
+			opts = append(opts, konduit.WithHelmVersion(c.HelmVersion))
+		}
+	}
+
+	if c.Watch {
+		switch {
+		case c.Show != "":
+			return &usageError{errors.New("can't use --watch with --show")}
+		case len(args) == 0 || args[0] != renderCommand:
+			return &usageError{fmt.Errorf("--watch can only be used with the %s command", renderCommand)}
+		}
+
+		return c.watch(ctx, g, args, opts)
 	}
 
-	k, err := konduit.New(c.Args[1:], c.Values, opts...)
+	k, err := konduit.New(args, c.Values, opts...)
 	if err != nil {
-		return fmt.Errorf("init: %w", err)
+		return &usageError{fmt.Errorf("init: %w", err)}
 	}
 
-	if c.Show {
-		cmd, err := k.Construct()
+	if c.Show != "" {
+		return c.show(ctx, g, k)
+	}
+
+	if c.WorkDir != "" || c.KeepWorkDir {
+		defer func() {
+			if dir := k.WorkDir(); dir != "" {
+				g.Log.Info("kept work dir", "dir", dir, "artifacts", filepath.Join(dir, konduit.ArtifactsFile))
+			}
+		}()
+	}
+
+	if c.InProcess {
+		manifests, err := k.Render(ctx)
 		if err != nil {
-			return fmt.Errorf("construct invocation: %w", err)
+			return fmt.Errorf("render: %w", err)
 		}
 
-		enc := json.NewEncoder(g.Stdout)
-		enc.SetIndent("", "  ")
-		if err := enc.Encode(cmd); err != nil {
-			return fmt.Errorf("encode invocation: %w", err)
+		if _, err := g.Stdout.Write(manifests); err != nil {
+			return fmt.Errorf("write manifests: %w", err)
 		}
 
 		return nil
@@ -76,3 +144,15 @@ func (c *CUECmd) Run(ctx context.Context, g *Globals) error {
 
 	return nil
 }
+
+func parsePostRenderers(cmdlines []string) ([]konduit.PostRenderer, error) {
+	postRenderers := make([]konduit.PostRenderer, 0, len(cmdlines))
+	for _, cmdline := range cmdlines {
+		postRenderer, err := konduit.ParsePostRenderer(cmdline)
+		if err != nil {
+			return nil, err
+		}
+		postRenderers = append(postRenderers, postRenderer)
+	}
+	return postRenderers, nil
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+// fakeHelm prints the last values file it is passed, as the manifests.
+const fakeHelm = `#!/bin/sh
+for arg; do last="$arg"; done
+cat "$last"
+`
+
+func TestCUECmd_RecordReplay(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	helm := filepath.Join(dir, "helm")
+	values := filepath.Join(dir, "values.cue")
+	cassette := filepath.Join(dir, "cassette.yaml")
+	require.NoError(t, os.WriteFile(helm, []byte(fakeHelm), 0o755))
+	require.NoError(t, os.WriteFile(values, []byte("greeting: \"hello\"\n"), 0o644))
+
+	run := func(g *Globals, workDir string) string {
+		t.Helper()
+
+		var stdout bytes.Buffer
+		g.Log = Log{Logger: slog.New(slog.DiscardHandler)}
+		g.Stdout = &stdout
+
+		cmd := &CUECmd{
+			Values:      []string{values},
+			CUEFlags:    CUEFlags{CUEBaseDir: dir, NoCache: true},
+			Args:        []string{"--", "template", "web", "./chart"},
+			HelmCommand: helm,
+			WorkDir:     workDir,
+		}
+
+		require.NoError(t, cmd.Run(t.Context(), g))
+		return stdout.String()
+	}
+
+	recorded := run(&Globals{Record: cassette}, filepath.Join(dir, "record"))
+	assert.Equal(t, "greeting: hello\n", recorded)
+
+	c, err := konduit.LoadCassette(cassette)
+	require.NoError(t, err)
+	require.Len(t, c.Interactions, 1)
+	assert.Equal(t, []string{"template", "web", "./chart", "--values", konduit.WorkDirPlaceholder + "/evaluated.yaml"}, c.Interactions[0].Args)
+
+	// The cassette is replayed with a different work dir, as on another
+	// machine.
+	replayed := run(&Globals{Replay: cassette}, filepath.Join(dir, "replay"))
+	assert.Equal(t, recorded, replayed)
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/format"
+	cueyaml "cuelang.org/go/encoding/yaml"
+	"github.com/goccy/go-yaml"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+type EvalCmd struct {
+	Values  []string `short:"v" help:"Helm values files to be evaluated by CUE."`
+	Patches []string `short:"p" help:"Kustomize patches files to be evaluated by CUE."`
+
+	CUEFlags `embed:""`
+
+	Output string `short:"o" enum:"yaml,json,cue" default:"yaml" help:"Output format (yaml, json or cue)."`
+	Merge  bool   `help:"Merge evaluated values with static YAML values files, following Helm's precedence."`
+}
+
+func (c *EvalCmd) Validate() error {
+	if len(c.Values) == 0 && len(c.Patches) == 0 {
+		return errors.New("no values or patches files provided")
+	}
+	return nil
+}
+
+func (c *EvalCmd) Run(ctx context.Context, g *Globals) error {
+	eval := c.Evaluator(g.Log.Logger)
+
+	_, staticValues := konduit.PartitionFiles(eval, c.Values)
+	if len(staticValues) > 0 && !c.Merge {
+		return &usageError{fmt.Errorf("static values files can only be evaluated with --merge: %s", strings.Join(staticValues, ", "))}
+	}
+
+	_, staticPatches := konduit.PartitionFiles(eval, c.Patches)
+	if len(staticPatches) > 0 {
+		return &usageError{fmt.Errorf("only CUE patches files can be evaluated: %s", strings.Join(staticPatches, ", "))}
+	}
+
+	ctx, cancel := c.EvalContext(ctx)
+	defer cancel()
+
+	var sections []evalSection
+	if len(c.Values) > 0 {
+		raw, err := c.evaluateValues(ctx, eval)
+		if err != nil {
+			return err
+		}
+		sections = append(sections, evalSection{name: "values", raw: raw})
+	}
+
+	if len(c.Patches) > 0 {
+		result, err := eval.Evaluate(ctx, c.Patches)
+		if err != nil {
+			return fmt.Errorf("evaluate patches: %w", &konduit.EvaluationError{Err: err})
+		}
+		sections = append(sections, evalSection{name: "patches", raw: result.Raw})
+	}
+
+	value, err := sectionsValue(cuecontext.New(), sections)
+	if err != nil {
+		return err
+	}
+
+	out, err := encodeValue(value, c.Output)
+	if err != nil {
+		return err
+	}
+
+	if _, err := g.Stdout.Write(out); err != nil {
+		return fmt.Errorf("write output: %w", err)
+	}
+
+	return nil
+}
+
+// evaluateValues returns the evaluated values as YAML, merged with any static
+// values files.
+func (c *EvalCmd) evaluateValues(ctx context.Context, eval konduit.Evaluator) ([]byte, error) {
+	if !c.Merge {
+		result, err := eval.Evaluate(ctx, c.Values)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate values: %w", &konduit.EvaluationError{Err: err})
+		}
+		return result.Raw, nil
+	}
+
+	values, err := evaluateValues(ctx, eval, c.Values)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := yaml.Marshal(values)
+	if err != nil {
+		return nil, fmt.Errorf("encode values: %w", err)
+	}
+
+	return raw, nil
+}
+
+type evalSection struct {
+	name string
+	raw  []byte
+}
+
+// sectionsValue returns the value of a single section, or a struct with a
+// field for each section if there are several.
+func sectionsValue(ctx *cue.Context, sections []evalSection) (cue.Value, error) {
+	exprs := make([]ast.Expr, len(sections))
+	for idx, section := range sections {
+		exprs[idx] = ast.NewStruct()
+		if len(bytes.TrimSpace(section.raw)) > 0 {
+			f, err := cueyaml.Extract(section.name, section.raw)
+			if err != nil {
+				return cue.Value{}, fmt.Errorf("extract %s: %w", section.name, err)
+			}
+			exprs[idx] = &ast.StructLit{Elts: f.Decls}
+		}
+	}
+
+	if len(sections) == 1 {
+		return ctx.BuildExpr(exprs[0]), nil
+	}
+
+	combined := &ast.StructLit{}
+	for idx, section := range sections {
+		combined.Elts = append(combined.Elts, &ast.Field{Label: ast.NewIdent(section.name), Value: exprs[idx]})
+	}
+
+	return ctx.BuildExpr(combined), nil
+}
+
+func encodeValue(v cue.Value, output string) ([]byte, error) {
+	switch output {
+	case "json":
+		data, err := v.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("encode JSON: %w", err)
+		}
+
+		var out bytes.Buffer
+		if err := json.Indent(&out, data, "", "  "); err != nil {
+			return nil, fmt.Errorf("indent JSON: %w", err)
+		}
+		out.WriteByte('\n')
+		return out.Bytes(), nil
+
+	case "cue":
+		var node ast.Node = v.Syntax(cue.Final(), cue.Concrete(true))
+		if s, ok := node.(*ast.StructLit); ok {
+			node = &ast.File{Decls: s.Elts}
+		}
+
+		out, err := format.Node(node, format.Simplify())
+		if err != nil {
+			return nil, fmt.Errorf("format CUE: %w", err)
+		}
+		return out, nil
+
+	default:
+		out, err := cueyaml.Encode(v)
+		if err != nil {
+			return nil, fmt.Errorf("encode YAML: %w", err)
+		}
+		return out, nil
+	}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEvalCmd_Merge(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		cue    string
+		static string
+		want   string
+	}{
+		{
+			name:   "merges static values over evaluated values",
+			cue:    "image: {repository: \"nginx\", tag: \"1.25\"}\nreplicas: 1\n",
+			static: "image:\n  tag: \"1.26\"\n",
+			want:   "image:\n  repository: nginx\n  tag: \"1.26\"\nreplicas: 1\n",
+		},
+		{
+			name:   "keeps evaluated nulls that remove chart defaults",
+			cue:    "image: {repository: \"nginx\", tag: null}\nresources: null\n",
+			static: "replicas: 3\n",
+			want:   "image:\n  repository: nginx\n  tag: null\nreplicas: 3\nresources: null\n",
+		},
+		{
+			name:   "overrides evaluated values with static nulls",
+			cue:    "image: {repository: \"nginx\", tag: \"1.25\"}\n",
+			static: "image:\n  tag: null\n",
+			want:   "image:\n  repository: nginx\n  tag: null\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			values := filepath.Join(dir, "values.cue")
+			static := filepath.Join(dir, "overrides.yaml")
+			require.NoError(t, os.WriteFile(values, []byte(tt.cue), 0o644))
+			require.NoError(t, os.WriteFile(static, []byte(tt.static), 0o644))
+
+			var stdout bytes.Buffer
+			g := &Globals{Log: Log{Logger: slog.New(slog.DiscardHandler)}, Stdout: &stdout}
+			cmd := &EvalCmd{
+				Values:   []string{values, static},
+				CUEFlags: CUEFlags{CUEBaseDir: dir, NoCache: true},
+				Output:   "yaml",
+				Merge:    true,
+			}
+
+			require.NoError(t, cmd.Run(t.Context(), g))
+			assert.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+// Exit codes for failures that originate in Konduit. When Helm, Timoni or a
+// post-renderer fails, Konduit exits with the code of the failed process
+// instead, so these are chosen to stay clear of the codes those tools use,
+// such as the 1 that Helm exits with on any error.
+const (
+	ExitCodeUsage      = 80
+	ExitCodeConfig     = 90
+	ExitCodeTestFailed = 91
+	ExitCodeInternal   = 100
+	ExitCodeNotFound   = 127
+)
+
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string { return e.err.Error() }
+func (e *usageError) Unwrap() error { return e.err }
+
+func exitCode(err error) int {
+	var (
+		exitErr  *exec.ExitError
+		evalErr  *konduit.EvaluationError
+		usageErr *usageError
+		testErr  *testFailedError
+	)
+
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	case errors.Is(err, exec.ErrNotFound):
+		return ExitCodeNotFound
+	case errors.As(err, &evalErr):
+		return ExitCodeConfig
+	case errors.As(err, &usageErr):
+		return ExitCodeUsage
+	case errors.As(err, &testErr):
+		return ExitCodeTestFailed
+	default:
+		return ExitCodeInternal
+	}
+}
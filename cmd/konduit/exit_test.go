@@ -0,0 +1,72 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+func TestExitCode(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{
+			name: "exits with the code of a failed process",
+			err:  fmt.Errorf("execute: %w", &exec.ExitError{Command: "helm", Code: 1}),
+			want: 1,
+		},
+		{
+			name: "exits with the code of a process terminated by a signal",
+			err:  fmt.Errorf("execute: %w", &exec.ExitError{Command: "helm", Code: 143, Signal: syscall.SIGTERM}),
+			want: 143,
+		},
+		{
+			name: "prefers the code of a failed process over errors wrapping it",
+			err:  &usageError{fmt.Errorf("run: %w", &exec.ExitError{Command: "helm", Code: 2})},
+			want: 2,
+		},
+		{
+			name: "exits with 127 when an executable is not found",
+			err:  fmt.Errorf("find executable %q: %w", "helm", exec.ErrNotFound),
+			want: ExitCodeNotFound,
+		},
+		{
+			name: "exits with a usage code on invalid flags",
+			err:  &usageError{errors.New("invalid scope")},
+			want: ExitCodeUsage,
+		},
+		{
+			name: "exits with a config code when evaluation fails",
+			err:  fmt.Errorf("evaluate values: %w", &konduit.EvaluationError{Err: errors.New("conflicting values")}),
+			want: ExitCodeConfig,
+		},
+		{
+			name: "exits with a dedicated code when tests fail",
+			err:  &testFailedError{failed: 2},
+			want: ExitCodeTestFailed,
+		},
+		{
+			name: "exits with an internal code on other errors",
+			err:  errors.New("write file: permission denied"),
+			want: ExitCodeInternal,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, exitCode(tt.err))
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/jace-ys/konduit/internal/flux"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+type ExportCmd struct {
+	Flux ExportFluxCmd `cmd:"" help:"Export a Flux HelmRelease with evaluated values and patches."`
+}
+
+type ExportFluxCmd struct {
+	Values  []string `short:"v" help:"Helm values files to be evaluated by CUE."`
+	Patches []string `short:"p" help:"Kustomize patches files to be evaluated by CUE."`
+
+	CUEFlags `embed:""`
+
+	Name            string `required:"" help:"Name of the HelmRelease."`
+	Namespace       string `help:"Namespace of the HelmRelease."`
+	Interval        string `default:"10m" help:"Interval at which to reconcile the HelmRelease."`
+	ReleaseName     string `help:"Name of the Helm release. If empty, it is derived by Flux."`
+	TargetNamespace string `help:"Namespace to install the Helm release into."`
+
+	Chart        string `help:"Name or path of the Helm chart, used with --source."`
+	ChartVersion string `help:"Version constraint of the Helm chart, used with --source."`
+	Source       string `help:"Source of the Helm chart, in the format <kind>/<name>.<namespace>."`
+	ChartRef     string `help:"Reference to a HelmChart or OCIRepository, in the format <kind>/<name>.<namespace>."`
+}
+
+func (c *ExportFluxCmd) Run(ctx context.Context, g *Globals) error {
+	release := &flux.HelmRelease{
+		APIVersion: flux.HelmReleaseAPIVersion,
+		Kind:       flux.HelmReleaseKind,
+		Metadata: flux.Metadata{
+			Name:      c.Name,
+			Namespace: c.Namespace,
+		},
+		Spec: flux.HelmReleaseSpec{
+			Interval:        c.Interval,
+			ReleaseName:     c.ReleaseName,
+			TargetNamespace: c.TargetNamespace,
+		},
+	}
+
+	if err := c.setChart(release); err != nil {
+		return &usageError{err}
+	}
+
+	if err := release.Validate(); err != nil {
+		return &usageError{fmt.Errorf("validate HelmRelease: %w", err)}
+	}
+
+	eval := c.Evaluator(g.Log.Logger)
+
+	ctx, cancel := c.EvalContext(ctx)
+	defer cancel()
+
+	values, err := evaluateValues(ctx, eval, c.Values)
+	if err != nil {
+		return err
+	}
+	release.Spec.Values = values
+
+	patches, err := readEvaluated(ctx, eval, c.Patches)
+	if err != nil {
+		return fmt.Errorf("evaluate patches: %w", err)
+	}
+
+	release.Spec.PostRenderers, err = flux.MakePostRenderers(patches...)
+	if err != nil {
+		return fmt.Errorf("define post-renderers: %w", err)
+	}
+
+	enc := yaml.NewEncoder(g.Stdout, yaml.UseLiteralStyleIfMultiline(true))
+	if err := enc.Encode(release); err != nil {
+		return fmt.Errorf("encode HelmRelease: %w", err)
+	}
+
+	return nil
+}
+
+func (c *ExportFluxCmd) setChart(release *flux.HelmRelease) error {
+	switch {
+	case c.ChartRef != "" && c.Source != "":
+		return errors.New("can't use --chart-ref and --source at the same time")
+
+	case c.ChartRef != "":
+		ref, err := flux.ParseSourceReference(c.ChartRef)
+		if err != nil {
+			return fmt.Errorf("parse chart ref: %w", err)
+		}
+		release.Spec.ChartRef = ref
+
+	case c.Source != "":
+		if c.Chart == "" {
+			return errors.New("--chart is required with --source")
+		}
+
+		ref, err := flux.ParseSourceReference(c.Source)
+		if err != nil {
+			return fmt.Errorf("parse source: %w", err)
+		}
+
+		release.Spec.Chart = &flux.HelmChartTemplate{
+			Spec: flux.HelmChartTemplateSpec{
+				Chart:     c.Chart,
+				Version:   c.ChartVersion,
+				SourceRef: *ref,
+			},
+		}
+	}
+
+	return nil
+}
+
+// evaluateValues merges the evaluated result of files supported by the
+// evaluator with the remaining static files, following Helm's precedence.
+func evaluateValues(ctx context.Context, eval konduit.Evaluator, files []string) (map[string]any, error) {
+	toEvaluate, static := konduit.PartitionFiles(eval, files)
+	values := make([]map[string]any, 0, len(static)+1)
+
+	if len(toEvaluate) > 0 {
+		result, err := eval.Evaluate(ctx, toEvaluate)
+		if err != nil {
+			return nil, fmt.Errorf("evaluate values: %w", &konduit.EvaluationError{Err: err})
+		}
+		values = append(values, result.Data)
+	}
+
+	for _, file := range static {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+
+		decoded, err := konduit.DecodeValues(content)
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, decoded)
+	}
+
+	return konduit.MergeValues(values...), nil
+}
+
+// readEvaluated returns the evaluated result of files supported by the
+// evaluator, followed by the contents of the remaining static files.
+func readEvaluated(ctx context.Context, eval konduit.Evaluator, files []string) ([][]byte, error) {
+	toEvaluate, static := konduit.PartitionFiles(eval, files)
+	docs := make([][]byte, 0, len(static)+1)
+
+	if len(toEvaluate) > 0 {
+		result, err := eval.Evaluate(ctx, toEvaluate)
+		if err != nil {
+			return nil, &konduit.EvaluationError{Err: err}
+		}
+		docs = append(docs, result.Raw)
+	}
+
+	for _, file := range static {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("read file: %w", err)
+		}
+		docs = append(docs, content)
+	}
+
+	return docs, nil
+}
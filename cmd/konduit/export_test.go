@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportFluxCmd(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join("testdata", "flux")
+	golden, err := os.ReadFile(filepath.Join(dir, "helmrelease.yaml"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		cmd     ExportFluxCmd
+		want    string
+		wantErr string
+	}{
+		{
+			name: "exports a HelmRelease with evaluated values and patches",
+			cmd: ExportFluxCmd{
+				Values:          []string{filepath.Join(dir, "values.cue"), filepath.Join(dir, "overrides.yaml")},
+				Patches:         []string{filepath.Join(dir, "patches.cue"), filepath.Join(dir, "patches.yaml")},
+				Name:            "web",
+				Namespace:       "flux-system",
+				Interval:        "5m",
+				ReleaseName:     "web",
+				TargetNamespace: "apps",
+				Chart:           "nginx",
+				ChartVersion:    ">=15.0.0",
+				Source:          "HelmRepository/bitnami.flux-system",
+			},
+			want: string(golden),
+		},
+		{
+			name: "exports a HelmRelease with a chart ref",
+			cmd: ExportFluxCmd{
+				Name:     "web",
+				Interval: "10m",
+				ChartRef: "OCIRepository/nginx",
+			},
+			want: `apiVersion: helm.toolkit.fluxcd.io/v2
+kind: HelmRelease
+metadata:
+  name: web
+spec:
+  interval: 10m
+  chartRef:
+    kind: OCIRepository
+    name: nginx
+`,
+		},
+		{
+			name:    "fails without a chart",
+			cmd:     ExportFluxCmd{Name: "web"},
+			wantErr: "exactly one of chart or chartRef is required",
+		},
+		{
+			name:    "fails with a chart ref and a source",
+			cmd:     ExportFluxCmd{Name: "web", ChartRef: "HelmChart/web", Source: "HelmRepository/bitnami", Chart: "nginx"},
+			wantErr: "can't use --chart-ref and --source at the same time",
+		},
+		{
+			name:    "fails with a source but no chart",
+			cmd:     ExportFluxCmd{Name: "web", Source: "HelmRepository/bitnami"},
+			wantErr: "--chart is required with --source",
+		},
+		{
+			name: "fails on patches Flux can't express",
+			cmd: ExportFluxCmd{
+				Patches:  []string{filepath.Join(dir, "unsupported.yaml")},
+				Name:     "web",
+				ChartRef: "HelmChart/web",
+			},
+			wantErr: "fields not supported by Flux post-renderers: namePrefix",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout bytes.Buffer
+			g := &Globals{Log: Log{Logger: slog.New(slog.DiscardHandler)}, Stdout: &stdout}
+			tt.cmd.CUEFlags = CUEFlags{CUEBaseDir: dir, NoCache: true}
+
+			err := tt.cmd.Run(t.Context(), g)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/jace-ys/konduit/internal/cache"
+	"github.com/jace-ys/konduit/pkg/cueval"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+type CUEFlags struct {
+	Scopes        []string `short:"s" sep:"none" help:"JSON/YAML data (or @filename) to inject under the #Konduit definition."`
+	CUEBaseDir    string   `help:"Base directory for import path resolution. If empty, the current directory is used."`
+	CUEModuleRoot string   `help:"Directory that contains the cue.mod directory and packages."`
+
+	EvalTimeout time.Duration `help:"Maximum time to spend evaluating CUE values and patches. If zero, there is no limit."`
+
+	NoCache  bool   `env:"KONDUIT_NO_CACHE" help:"Disable caching of CUE evaluation results."`
+	CacheDir string `env:"KONDUIT_CACHE_DIR" help:"Directory to cache CUE evaluation results in. If empty, the user cache directory is used."`
+}
+
+func (f *CUEFlags) Evaluator(logger *slog.Logger) konduit.Evaluator {
+	eval := konduit.NewCUEEvaluator(
+		cueval.WithScopes(f.Scopes...),
+		cueval.WithLoadDir(f.CUEBaseDir),
+		cueval.WithLoadModuleRoot(f.CUEModuleRoot),
+		cueval.WithLogger(logger),
+	)
+
+	if f.NoCache {
+		return eval
+	}
+
+	dir, err := cacheDir(f.CacheDir)
+	if err != nil {
+		return eval
+	}
+
+	return konduit.NewCachedEvaluator(eval, cache.New(dir), version+"+"+commit)
+}
+
+// EvalContext returns a context that is cancelled once the evaluation
+// timeout has passed, if one is set.
+func (f *CUEFlags) EvalContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if f.EvalTimeout > 0 {
+		return context.WithTimeout(ctx, f.EvalTimeout)
+	}
+	return context.WithCancel(ctx)
+}
+
+func cacheDir(dir string) (string, error) {
+	if dir != "" {
+		return dir, nil
+	}
+	return cache.DefaultDir()
+}
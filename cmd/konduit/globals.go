@@ -7,21 +7,34 @@ import (
 	"io"
 	"log/slog"
 	"runtime"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/pkg/konduit"
 )
 
 type Globals struct {
 	Version VersionCmd `cmd:"" help:"Print version information."`
 
-	Log    Log       `embed:"" prefix:"log." envprefix:"LOG_"`
-	Stdout io.Writer `kong:"-"`
-	Stderr io.Writer `kong:"-"`
+	Log         Log           `embed:"" prefix:"log."`
+	GracePeriod time.Duration `env:"KONDUIT_GRACE_PERIOD" default:"10s" help:"Time to wait for Helm and post-renderers to exit after forwarding an interrupt, before killing them."`
+	Env         []string      `sep:"none" help:"Environment variables to set for Helm and post-renderers, in KEY=VALUE form."`
+	UnsetEnv    []string      `help:"Environment variables to remove for Helm and post-renderers. Supports * wildcards."`
+	AllowEnv    []string      `help:"Only pass environment variables matching these patterns to Helm and post-renderers. Supports * wildcards."`
+	Trace       string        `env:"KONDUIT_TRACE" help:"Export a trace of each stage as OTLP JSON, appended to a file or sent to an OTLP/HTTP collector at an http(s) URL."`
+	Record      string        `xor:"cassette" type:"path" help:"Record the commands run by Konduit, with their input and output, to a cassette file."`
+	Replay      string        `xor:"cassette" type:"existingfile" help:"Replay the commands run by Konduit from a cassette file, instead of running them."`
+	Stdout      io.Writer     `kong:"-"`
+	Stderr      io.Writer     `kong:"-"`
 }
 
 type Log struct {
 	*slog.Logger
 
-	Level  string `env:"LEVEL" default:"info" enum:"debug,info,warn,error" help:"Configure the log level."`
-	Format string `env:"FORMAT" default:"text" enum:"text,json" help:"Configure the log format."`
+	Level  string `env:"KONDUIT_LOG_LEVEL,LOG_LEVEL" default:"info" enum:"debug,info,warn,error" help:"Configure the log level."`
+	Format string `env:"KONDUIT_LOG_FORMAT,LOG_FORMAT" default:"text" enum:"text,json" help:"Configure the log format."`
 }
 
 func (l *Log) AfterApply(g *Globals) error {
@@ -54,6 +67,54 @@ func (l *Log) AfterApply(g *Globals) error {
 	return nil
 }
 
+func (g *Globals) Validate() error {
+	for _, kv := range g.Env {
+		if key, _, ok := strings.Cut(kv, "="); !ok || key == "" {
+			return fmt.Errorf("invalid environment variable %q, expected KEY=VALUE", kv)
+		}
+	}
+	return nil
+}
+
+// Runner returns a runner for child processes that honours the configured
+// grace period and environment, and records or replays commands if enabled.
+// The runner applies opts to every command after the global options.
+func (g *Globals) Runner(opts ...exec.RunOption) (konduit.Runner, error) {
+	if g.Replay != "" {
+		cassette, err := konduit.LoadCassette(g.Replay)
+		if err != nil {
+			return nil, err
+		}
+		return konduit.NewReplayRunner(cassette), nil
+	}
+
+	runner := exec.NewOSRunner(slices.Concat([]exec.RunOption{
+		exec.WithLogger(g.Log.Logger),
+		exec.WithGracePeriod(g.GracePeriod),
+		exec.WithEnvAllowlist(g.AllowEnv...),
+		exec.WithoutEnv(g.UnsetEnv...),
+		// The konduit kustomize post-renderer logs and traces the same way as
+		// its parent. The variables are prefixed, as every child process
+		// inherits them.
+		exec.WithEnv("KONDUIT_LOG_LEVEL="+g.Log.Level, "KONDUIT_LOG_FORMAT="+g.Log.Format),
+		exec.WithEnv(g.traceEnv()...),
+		exec.WithEnv(g.Env...),
+	}, opts)...)
+
+	if g.Record != "" {
+		return konduit.NewRecordingRunner(runner, g.Record), nil
+	}
+
+	return runner, nil
+}
+
+func (g *Globals) traceEnv() []string {
+	if g.Trace == "" {
+		return nil
+	}
+	return []string{"KONDUIT_TRACE=" + g.Trace}
+}
+
 var (
 	version = "dev"
 	commit  = "unknown"
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jace-ys/konduit/pkg/cuegen"
+)
+
+type ImportCmd struct {
+	Files   []string `arg:"" type:"existingfile" help:"Helm values files to convert, such as one for each environment."`
+	Dir     string   `short:"d" type:"path" default:"." help:"Directory to write CUE files to."`
+	Package string   `default:"values" help:"Package name of the CUE files."`
+	Force   bool     `help:"Overwrite existing files."`
+}
+
+func (c *ImportCmd) Run(ctx context.Context, g *Globals) error {
+	docs := make([]cuegen.Document, len(c.Files))
+	names := make([]string, len(c.Files))
+	seen := make(map[string]string, len(c.Files))
+
+	for idx, file := range c.Files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("read values file: %w", err)
+		}
+		docs[idx] = cuegen.Document{Filename: file, Data: data}
+
+		name := environmentName(file)
+		if other, ok := seen[name]; ok {
+			return &usageError{fmt.Errorf("%s and %s would both be imported as %s", other, file, name)}
+		}
+		seen[name] = file
+		names[idx] = name
+	}
+
+	base, files, err := cuegen.Import(c.Package, docs...)
+	if err != nil {
+		return &usageError{fmt.Errorf("import values: %w", err)}
+	}
+
+	var outputs []scaffoldFile
+	if base != nil {
+		outputs = append(outputs, scaffoldFile{path: "values.cue", data: base})
+	}
+	if len(files) > 1 {
+		for idx, data := range files {
+			outputs = append(outputs, scaffoldFile{path: filepath.Join(names[idx], "values.cue"), data: data})
+		}
+	}
+
+	if !c.Force {
+		for _, f := range outputs {
+			if fileExists(filepath.Join(c.Dir, f.path)) {
+				return &usageError{fmt.Errorf("%s already exists, use --force to overwrite it", f.path)}
+			}
+		}
+	}
+
+	for _, f := range outputs {
+		if err := writeScaffoldFile(filepath.Join(c.Dir, f.path), f.data); err != nil {
+			return err
+		}
+		fmt.Fprintf(g.Stdout, "created\t%s\n", f.path)
+	}
+
+	return nil
+}
+
+// environmentName names the CUE file for a values file after its directory if
+// it is called values.yaml, such as production/values.yaml, or otherwise after
+// the file, such as values-production.yaml.
+func environmentName(file string) string {
+	name := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+	if name == "values" {
+		return filepath.Base(filepath.Dir(file))
+	}
+
+	for _, prefix := range []string{"values-", "values."} {
+		if env, ok := strings.CutPrefix(name, prefix); ok && env != "" {
+			return env
+		}
+	}
+
+	return name
+}
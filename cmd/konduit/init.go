@@ -0,0 +1,220 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/mod/modfile"
+	"helm.sh/helm/v3/pkg/chart/loader"
+
+	"github.com/jace-ys/konduit/pkg/cuegen"
+)
+
+// Module dependency on the Kubernetes schemas from the CUE Central Registry,
+// for patches and libraries to import.
+const (
+	k8sModule        = "cue.dev/x/k8s.io@v0"
+	k8sModuleVersion = "v0.6.0"
+)
+
+type InitCmd struct {
+	Chart        string   `arg:"" type:"path" help:"Path to a local chart directory or packaged .tgz chart."`
+	Dir          string   `short:"d" type:"path" default:"." help:"Directory to generate files in. A CUE module is created here if it doesn't already contain one."`
+	Name         string   `help:"Name of the directory for the release's values and patches. If empty, the chart name is used."`
+	Module       string   `help:"Path of the CUE module to create. If empty, one is derived from the directory name."`
+	Environments []string `short:"e" default:"development,production" help:"Environments to generate values and scope files for."`
+	Force        bool     `help:"Overwrite existing values, patches and schema files."`
+}
+
+type scaffoldFile struct {
+	path string
+	data []byte
+	// shared files are left alone if they already exist, as they may be used
+	// by other releases.
+	shared bool
+}
+
+func (c *InitCmd) Run(ctx context.Context, g *Globals) error {
+	chart, err := loader.Load(c.Chart)
+	if err != nil {
+		return &usageError{fmt.Errorf("load chart: %w", err)}
+	}
+
+	name := c.Name
+	if name == "" {
+		name = chart.Name()
+	}
+
+	var defaults []byte
+	for _, f := range chart.Raw {
+		if f.Name == "values.yaml" {
+			defaults = f.Data
+		}
+	}
+
+	schema, err := cuegen.Schema("values.yaml", defaults, "schema")
+	if err != nil {
+		return fmt.Errorf("generate schema: %w", err)
+	}
+
+	module, moduleFile, err := c.module()
+	if err != nil {
+		return err
+	}
+
+	files := []scaffoldFile{
+		{path: filepath.Join(name, "schema", "schema.cue"), data: schema},
+		{path: filepath.Join(name, "values.cue"), data: valuesFile(path.Join(module, name, "schema"))},
+		{path: filepath.Join(name, "patches.cue"), data: patchesFile(name)},
+	}
+
+	if moduleFile != nil {
+		files = append(files, scaffoldFile{path: filepath.Join("cue.mod", "module.cue"), data: moduleFile, shared: true})
+	}
+
+	for _, env := range c.Environments {
+		scope, err := json.MarshalIndent(map[string]any{"environment": env}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("encode scope: %w", err)
+		}
+
+		files = append(files,
+			scaffoldFile{path: filepath.Join(name, env, "values.cue"), data: environmentValuesFile(env)},
+			scaffoldFile{path: filepath.Join("data", env+".json"), data: append(scope, '\n'), shared: true},
+		)
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+
+	if !c.Force {
+		for _, f := range files {
+			if !f.shared && fileExists(filepath.Join(c.Dir, f.path)) {
+				return &usageError{fmt.Errorf("%s already exists, use --force to overwrite it", f.path)}
+			}
+		}
+	}
+
+	for _, f := range files {
+		if f.shared && fileExists(filepath.Join(c.Dir, f.path)) {
+			fmt.Fprintf(g.Stdout, "skipped\t%s\n", f.path)
+			continue
+		}
+
+		if err := writeScaffoldFile(filepath.Join(c.Dir, f.path), f.data); err != nil {
+			return err
+		}
+		fmt.Fprintf(g.Stdout, "created\t%s\n", f.path)
+	}
+
+	chartPath := c.Chart
+	if rel, err := filepath.Rel(c.Dir, c.Chart); err == nil && !strings.HasPrefix(rel, "..") {
+		chartPath = rel
+	}
+
+	fmt.Fprintf(g.Stdout, "\nFrom %s, render the chart with:\n", c.Dir)
+	for _, env := range c.Environments {
+		fmt.Fprintf(g.Stdout, "\n  konduit cue -v %s -v %s -p %s -s @%s -- template %s %s\n",
+			filepath.Join(name, "values.cue"),
+			filepath.Join(name, env, "values.cue"),
+			filepath.Join(name, "patches.cue"),
+			filepath.Join("data", env+".json"),
+			name, chartPath,
+		)
+	}
+
+	return nil
+}
+
+// module returns the path of the CUE module in the target directory, along
+// with the contents of a new module file if there isn't one yet.
+func (c *InitCmd) module() (string, []byte, error) {
+	filename := filepath.Join(c.Dir, "cue.mod", "module.cue")
+
+	data, err := os.ReadFile(filename)
+	if err == nil {
+		f, err := modfile.ParseNonStrict(data, filename)
+		if err != nil {
+			return "", nil, fmt.Errorf("parse CUE module: %w", err)
+		}
+		return f.ModulePath(), nil, nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return "", nil, fmt.Errorf("read CUE module: %w", err)
+	}
+
+	module := c.Module
+	if module == "" {
+		module = path.Join("cue.example", filepath.Base(c.Dir))
+	}
+
+	data, err = modfile.Format(&modfile.File{
+		Module:   module,
+		Language: &modfile.Language{Version: cue.LanguageVersion()},
+		Deps: map[string]*modfile.Dep{
+			k8sModule: {Version: k8sModuleVersion, Default: true},
+		},
+	})
+	if err != nil {
+		return "", nil, fmt.Errorf("format CUE module: %w", err)
+	}
+
+	return module, data, nil
+}
+
+func fileExists(name string) bool {
+	_, err := os.Stat(name)
+	return err == nil
+}
+
+func valuesFile(schemaImport string) []byte {
+	return []byte(fmt.Sprintf(`package values
+
+import "%s"
+
+// #Konduit holds the scope data passed with -s, such as the files in data/.
+#Konduit: environment: string
+
+// Values shared by all environments, validated against the chart's schema.
+schema.#Values
+`, schemaImport))
+}
+
+func environmentValuesFile(env string) []byte {
+	return []byte(fmt.Sprintf(`package values
+
+#Konduit: environment: %q
+`, env))
+}
+
+func patchesFile(name string) []byte {
+	return []byte(fmt.Sprintf(`package patches
+
+// Kustomize patches applied to the manifests rendered by the chart. For
+// example, to add a label to every resource:
+//
+//	commonLabels: "app.kubernetes.io/part-of": %q
+//
+// Patches can be validated by importing the Kubernetes schemas, such as
+// corev1 "cue.dev/x/k8s.io/api/core/v1".
+`, name))
+}
+
+func writeScaffoldFile(filename string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(filename), 0o755); err != nil {
+		return fmt.Errorf("create dir: %w", err)
+	}
+
+	if err := os.WriteFile(filename, data, 0o644); err != nil {
+		return fmt.Errorf("write %s: %w", filename, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"bytes"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitCmd(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	g := &Globals{Log: Log{Logger: slog.New(slog.DiscardHandler)}, Stdout: new(bytes.Buffer)}
+
+	cmd := &InitCmd{
+		Chart:        filepath.Join("..", "..", "examples", "logstash", "eck-logstash-0.17.0.tgz"),
+		Dir:          dir,
+		Module:       "example.com/deploy",
+		Environments: []string{"development", "production"},
+	}
+	require.NoError(t, cmd.Run(t.Context(), g))
+
+	want := `version: 9.2.0
+labels: {}
+annotations: {}
+count: 1
+config: {}
+podTemplate: {}
+monitoring: {}
+pipelines: []
+volumeClaimTemplates: []
+elasticsearchRefs: []
+services: []
+secureSettings: []
+`
+
+	tests := []struct {
+		name    string
+		env     string
+		scope   string
+		wantErr string
+	}{
+		{
+			name:  "evaluates development values with the development scope",
+			env:   "development",
+			scope: "development",
+		},
+		{
+			name:  "evaluates production values with the production scope",
+			env:   "production",
+			scope: "production",
+		},
+		{
+			name:    "fails with the scope of another environment",
+			env:     "development",
+			scope:   "production",
+			wantErr: "conflicting values",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout bytes.Buffer
+			g := &Globals{Log: Log{Logger: slog.New(slog.DiscardHandler)}, Stdout: &stdout}
+
+			eval := &EvalCmd{
+				Values: []string{
+					filepath.Join(dir, "eck-logstash", "values.cue"),
+					filepath.Join(dir, "eck-logstash", tt.env, "values.cue"),
+				},
+				CUEFlags: CUEFlags{
+					Scopes:     []string{"@" + filepath.Join(dir, "data", tt.scope+".json")},
+					CUEBaseDir: dir,
+					NoCache:    true,
+				},
+				Output: "yaml",
+			}
+			require.NoError(t, eval.Validate())
+
+			err := eval.Run(t.Context(), g)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, want, stdout.String())
+		})
+	}
+}
@@ -1,23 +1,34 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
 	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/internal/helm"
 	"github.com/jace-ys/konduit/internal/kustomize"
+	"github.com/jace-ys/konduit/internal/tracing"
+	"github.com/jace-ys/konduit/pkg/konduit"
 )
 
 type KustomizeCmd struct {
 	Manifests          *os.File `default:"-" arg:"" help:"Manifests file to run Kustomize on (use - for stdin)."`
-	Dir                string   `required:"" help:"Directory to run Kustomize on."`
+	Dir                string   `help:"Directory to run Kustomize on. If empty, the manifests are only piped through the post-renderers."`
+	Before             []string `sep:"none" help:"Post-renderer to run before Kustomize, as a command line. Repeat to run several in order."`
 	PostRenderer       string   `help:"Original Helm post-renderer command to invoke."`
 	PostRendererArgs   []string `help:"Original Helm post-renderer arguments to pass through."`
+	PostRendererPlugin bool     `help:"Resolve the original Helm post-renderer as a Helm 4 post-renderer plugin."`
+	After              []string `sep:"none" help:"Post-renderer to run after Kustomize and the original Helm post-renderer, as a command line. Repeat to run several in order."`
 	KustomizeCommand   string   `default:"kustomize" help:"Kustomize command or path to an executable."`
 	KustomizeBuildArgs []string `help:"Additional arguments to pass to Kustomize build."`
+	KeepIntermediate   bool     `help:"Keep the manifests file in the directory, and write the Kustomize output to it."`
 }
 
 func (c *KustomizeCmd) Run(ctx context.Context, g *Globals) error {
@@ -36,39 +47,124 @@ func (c *KustomizeCmd) Run(ctx context.Context, g *Globals) error {
 		return errors.New("no manifests from stdin")
 	}
 
-	manifests, err := kustomize.WriteManifests(c.Dir, c.Manifests)
+	runner, err := g.Runner()
 	if err != nil {
-		return fmt.Errorf("write manifests file: %w", err)
+		return err
+	}
+
+	stages, err := c.stages(g, runner)
+	if err != nil {
+		return err
+	}
+
+	// The stages run as a pipeline, so a post-renderer starts on the first
+	// manifests it receives. Kustomize is the exception, since it needs all of
+	// the manifests before it can apply patches.
+	return exec.Pipeline(ctx, c.Manifests, g.Stdout, stages...)
+}
+
+// stages returns the post-renderers before Kustomize, Kustomize if a directory
+// is set, the original Helm post-renderer and the post-renderers after it.
+func (c *KustomizeCmd) stages(g *Globals, runner konduit.Runner) ([]exec.Stage, error) {
+	stages := make([]exec.Stage, 0)
+
+	for _, cmdline := range c.Before {
+		postRenderer, err := konduit.ParsePostRenderer(cmdline)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, postRendererStage(runner, postRenderer.Command, postRenderer.Args))
+	}
+
+	if c.Dir != "" {
+		stages = append(stages, c.kustomizeStage(g, runner))
 	}
-	defer os.Remove(manifests)
 
-	runner := exec.NewOSRunner()
-	buildArgs := append([]string{"build", c.Dir}, c.KustomizeBuildArgs...)
+	if c.PostRenderer != "" {
+		postRenderer, postRendererArgs := c.PostRenderer, c.PostRendererArgs
+		if c.PostRendererPlugin {
+			var err error
+			postRenderer, postRendererArgs, err = resolvePostRendererPlugin(c.PostRenderer, c.PostRendererArgs)
+			if err != nil {
+				return nil, fmt.Errorf("resolve original post-renderer plugin: %w", err)
+			}
+			g.Log.Debug("resolved original post-renderer plugin", "plugin", c.PostRenderer, "command", postRenderer, "args", postRendererArgs)
+		}
+		stages = append(stages, postRendererStage(runner, postRenderer, postRendererArgs))
+	}
 
-	if c.PostRenderer == "" {
-		if err := runner.Run(ctx, c.KustomizeCommand, buildArgs); err != nil {
+	for _, cmdline := range c.After {
+		postRenderer, err := konduit.ParsePostRenderer(cmdline)
+		if err != nil {
+			return nil, err
+		}
+		stages = append(stages, postRendererStage(runner, postRenderer.Command, postRenderer.Args))
+	}
+
+	return stages, nil
+}
+
+func (c *KustomizeCmd) kustomizeStage(g *Globals, runner konduit.Runner) exec.Stage {
+	return func(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+		manifests, err := kustomize.WriteManifests(c.Dir, stdin)
+		if err != nil {
+			return fmt.Errorf("write manifests file: %w", err)
+		}
+		g.Log.Debug("wrote manifests file", "path", manifests)
+
+		if !c.KeepIntermediate {
+			defer os.Remove(manifests)
+		} else {
+			// The output of Kustomize is also written to a file, so that it
+			// can be compared with the output of the post-renderers after it.
+			var output bytes.Buffer
+			stdout = io.MultiWriter(stdout, &output)
+			defer func() {
+				if _, err := kustomize.WriteOutput(c.Dir, output.Bytes()); err != nil {
+					g.Log.Warn("failed to keep kustomize output", "error", err)
+				}
+			}()
+		}
+
+		buildArgs := append([]string{"build", c.Dir}, c.KustomizeBuildArgs...)
+		if err := c.runKustomize(ctx, runner, buildArgs, stdout); err != nil {
 			return fmt.Errorf("run kustomize: %w", err)
 		}
+
 		return nil
 	}
+}
 
-	pr, pw := io.Pipe()
-	errCh := make(chan error, 1)
+func (c *KustomizeCmd) runKustomize(ctx context.Context, runner konduit.Runner, args []string, stdout io.Writer) (err error) {
+	ctx, span := tracer.Start(ctx, "kustomize build", trace.WithAttributes(attribute.String("konduit.command", c.KustomizeCommand)))
+	defer func() { tracing.End(span, err) }()
+
+	return runner.Run(ctx, c.KustomizeCommand, args, exec.WithStdout(stdout))
+}
 
-	go func() {
-		defer pr.Close()
-		errCh <- runner.Run(ctx, c.PostRenderer, c.PostRendererArgs, exec.WithStdin(pr))
-	}()
+func postRendererStage(runner konduit.Runner, command string, args []string) exec.Stage {
+	return func(ctx context.Context, stdin io.Reader, stdout io.Writer) (err error) {
+		ctx, span := tracer.Start(ctx, "post-renderer", trace.WithAttributes(attribute.String("konduit.command", command)))
+		defer func() { tracing.End(span, err) }()
 
-	if err := runner.Run(ctx, c.KustomizeCommand, buildArgs, exec.WithStdout(pw)); err != nil {
-		pw.CloseWithError(err)
-		return fmt.Errorf("run kustomize: %w", err)
+		if err := runner.Run(ctx, command, args, exec.WithStdin(stdin), exec.WithStdout(stdout)); err != nil {
+			return fmt.Errorf("run post-renderer %s: %w", command, err)
+		}
+
+		return nil
+	}
+}
+
+func resolvePostRendererPlugin(name string, args []string) (string, []string, error) {
+	dirs, err := helm.PluginDirs()
+	if err != nil {
+		return "", nil, err
 	}
-	pw.Close()
 
-	if err := <-errCh; err != nil {
-		return fmt.Errorf("run original post-renderer: %w", err)
+	plugin, err := helm.FindPlugin(dirs, name, helm.PluginTypePostRenderer)
+	if err != nil {
+		return "", nil, err
 	}
 
-	return nil
+	return plugin.Command(args)
 }
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/exec"
+)
+
+// trapPostRenderer is a post-renderer that reports the signals it receives to
+// the file in $1, while it waits for manifests that never arrive.
+const trapPostRenderer = `#!/bin/sh
+trap 'echo TERM >> "$1"; exit 0' TERM
+trap 'echo INT >> "$1"; exit 0' INT
+touch "$1.ready"
+while :; do sleep 0.01; done
+`
+
+func TestKustomizeCmd_Shutdown(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	script := filepath.Join(dir, "post-render")
+	require.NoError(t, os.WriteFile(script, []byte(trapPostRenderer), 0o755))
+
+	reports := []string{filepath.Join(dir, "before"), filepath.Join(dir, "original"), filepath.Join(dir, "after")}
+	cmd := &KustomizeCmd{
+		Before:           []string{script + " " + reports[0]},
+		PostRenderer:     script,
+		PostRendererArgs: []string{reports[1]},
+		After:            []string{script + " " + reports[2]},
+	}
+
+	g := &Globals{Log: Log{Logger: slog.New(slog.DiscardHandler)}}
+	stages, err := cmd.stages(g, exec.NewOSRunner(exec.WithGracePeriod(5*time.Second)))
+	require.NoError(t, err)
+	require.Len(t, stages, 3)
+
+	// Helm is still rendering, so the manifests are never closed. They are
+	// read from a file like stdin, which the first post-renderer inherits.
+	manifests, helm, err := os.Pipe()
+	require.NoError(t, err)
+	defer manifests.Close()
+	defer helm.Close()
+
+	ctx, cancel := context.WithCancelCause(t.Context())
+	defer cancel(nil)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- exec.Pipeline(ctx, manifests, io.Discard, stages...)
+	}()
+
+	require.Eventually(t, func() bool {
+		for _, report := range reports {
+			if _, err := os.Stat(report + ".ready"); err != nil {
+				return false
+			}
+		}
+		return true
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel(&exec.SignalCause{Signal: os.Interrupt})
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "pipeline did not shut down")
+	}
+
+	for _, report := range reports {
+		signals, err := os.ReadFile(report)
+		require.NoError(t, err)
+		assert.Equal(t, "INT\n", string(signals), fmt.Sprintf("signals received by %s", filepath.Base(report)))
+	}
+}
@@ -2,26 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
 	"os"
-	"os/signal"
 	"syscall"
 
 	"github.com/alecthomas/kong"
+
+	"github.com/jace-ys/konduit/internal/exec"
 )
 
 type RootCmd struct {
 	Globals
 
+	Init      InitCmd      `cmd:"" help:"Generate CUE values and patches for a Helm chart."`
+	Import    ImportCmd    `cmd:"" help:"Convert Helm values files from YAML to CUE."`
 	CUE       CUECmd       `cmd:"" help:"Run Helm with CUE evaluation of Helm values and Kustomize patches."`
+	Eval      EvalCmd      `cmd:"" help:"Print evaluated values and patches."`
+	Vet       VetCmd       `cmd:"" help:"Validate values and patches without running Helm."`
 	Kustomize KustomizeCmd `cmd:"" hidden:"" help:"Run the Konduit-compatible Kustomize post-renderer."`
+	Export    ExportCmd    `cmd:"" help:"Export evaluated values and patches to other deployment tools."`
+	ArgoCD    ArgoCDCmd    `cmd:"" name:"argocd" help:"Run Konduit as an Argo CD Config Management Plugin."`
+	Test      TestCmd      `cmd:"" help:"Render releases and compare the manifests against snapshots."`
+	Cache     CacheCmd     `cmd:"" help:"Manage the cache of CUE evaluation results."`
 }
 
 func main() {
-	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-ctx.Done()
-		stop()
-	}()
+	ctx, stop := exec.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	root := RootCmd{
 		Globals: Globals{
@@ -43,5 +50,25 @@ func main() {
 		kong.BindTo(ctx, (*context.Context)(nil)),
 	)
 
-	cli.FatalIfErrorf(cli.Run())
+	command := "konduit"
+	if node := cli.Selected(); node != nil {
+		command = node.FullPath()
+	}
+
+	ctx, endTrace := root.StartTrace(ctx, command)
+	cli.BindTo(ctx, (*context.Context)(nil))
+
+	err := cli.Run()
+	endTrace(err)
+
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			// The failed process has already reported its error on stderr.
+			root.Log.Debug("command failed", "error", err)
+		} else {
+			cli.Errorf("%s", err)
+		}
+		cli.Exit(exitCode(err))
+	}
 }
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/alecthomas/kong"
+	"github.com/goccy/go-yaml"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+// ShowFormat is the format to print an invocation in. It is set by --show
+// alone, which prints JSON, or with a format such as --show=shell.
+type ShowFormat string
+
+const (
+	ShowFormatJSON  ShowFormat = "json"
+	ShowFormatYAML  ShowFormat = "yaml"
+	ShowFormatShell ShowFormat = "shell"
+)
+
+func (f *ShowFormat) Decode(ctx *kong.DecodeContext) error {
+	*f = ShowFormatJSON
+	if ctx.Scan.Peek().Type != kong.FlagValueToken {
+		return nil
+	}
+
+	value := ctx.Scan.Pop().Value
+	switch format := ShowFormat(fmt.Sprint(value)); format {
+	case ShowFormatJSON, ShowFormatYAML, ShowFormatShell:
+		*f = format
+	default:
+		return fmt.Errorf("show format must be one of json, yaml or shell but got %q", format)
+	}
+
+	return nil
+}
+
+func (f *ShowFormat) IsBool() bool {
+	return true
+}
+
+// show prints the invocation instead of running it.
+func (c *CUECmd) show(ctx context.Context, g *Globals, k *konduit.Instance) error {
+	if err := k.Prepare(ctx); err != nil {
+		return fmt.Errorf("prepare: %w", err)
+	}
+
+	if c.Show == ShowFormatShell {
+		script, err := k.Script(ctx)
+		if err != nil {
+			return fmt.Errorf("generate script: %w", err)
+		}
+
+		if _, err := g.Stdout.Write(script); err != nil {
+			return fmt.Errorf("write script: %w", err)
+		}
+
+		return nil
+	}
+
+	cmd, err := k.Construct(ctx)
+	if err != nil {
+		return fmt.Errorf("construct invocation: %w", err)
+	}
+
+	if c.Show == ShowFormatYAML {
+		data, err := yaml.MarshalWithOptions(cmd, yaml.UseLiteralStyleIfMultiline(true))
+		if err != nil {
+			return fmt.Errorf("encode invocation: %w", err)
+		}
+
+		if _, err := g.Stdout.Write(data); err != nil {
+			return fmt.Errorf("write invocation: %w", err)
+		}
+
+		return nil
+	}
+
+	enc := json.NewEncoder(g.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(cmd); err != nil {
+		return fmt.Errorf("encode invocation: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/internal/manifest"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+type TestCmd struct {
+	Config   string   `short:"c" type:"existingfile" default:"konduit-test.yaml" help:"Test configuration file. Paths in the file are relative to its directory."`
+	Update   bool     `short:"u" help:"Rewrite snapshots with the rendered manifests instead of comparing against them."`
+	Releases []string `arg:"" optional:"" help:"Names of the releases to test. If empty, all releases are tested."`
+}
+
+// TestConfig describes the releases to render and the snapshots to compare
+// them against. Top-level CUE settings apply to every release.
+type TestConfig struct {
+	CUEBaseDir    string   `yaml:"cueBaseDir"`
+	CUEModuleRoot string   `yaml:"cueModuleRoot"`
+	Scopes        []string `yaml:"scopes"`
+	HelmCommand   string   `yaml:"helmCommand"`
+
+	Releases []TestRelease `yaml:"releases"`
+
+	// dir is the directory of the config file, which paths in the config,
+	// including those in Helm arguments, are relative to.
+	dir string
+}
+
+type TestRelease struct {
+	Name     string   `yaml:"name"`
+	Args     []string `yaml:"args"`
+	Values   []string `yaml:"values"`
+	Patches  []string `yaml:"patches"`
+	Scopes   []string `yaml:"scopes"`
+	Snapshot string   `yaml:"snapshot"`
+
+	PostRenderersBefore []konduit.PostRenderer `yaml:"postRenderersBefore"`
+	PostRenderersAfter  []konduit.PostRenderer `yaml:"postRenderersAfter"`
+}
+
+type testFailedError struct {
+	failed int
+}
+
+func (e *testFailedError) Error() string {
+	return fmt.Sprintf("%d of the tested releases failed", e.failed)
+}
+
+func (c *TestCmd) Run(ctx context.Context, g *Globals) error {
+	config, releases, err := openTestConfig(c.Config, c.Releases)
+	if err != nil {
+		return err
+	}
+
+	runner, err := g.Runner(exec.WithDir(config.dir))
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, release := range releases {
+		manifests, err := config.render(ctx, release, runner, g.Log.Logger)
+		if err != nil {
+			fmt.Fprintf(g.Stdout, "FAIL\t%s\n%s\n", release.Name, err)
+			failed++
+			continue
+		}
+
+		if c.Update {
+			if err := writeSnapshot(release.Snapshot, manifests); err != nil {
+				return err
+			}
+			fmt.Fprintf(g.Stdout, "updated\t%s\n", release.Name)
+			continue
+		}
+
+		snapshot, err := os.ReadFile(release.Snapshot)
+		if errors.Is(err, fs.ErrNotExist) {
+			fmt.Fprintf(g.Stdout, "FAIL\t%s\nsnapshot %s not found, run with --update to create it\n", release.Name, release.Snapshot)
+			failed++
+			continue
+		} else if err != nil {
+			return fmt.Errorf("read snapshot: %w", err)
+		}
+
+		diff, err := manifest.Diff(snapshot, manifests)
+		if err != nil {
+			return fmt.Errorf("diff %s: %w", release.Name, err)
+		}
+
+		if diff != "" {
+			fmt.Fprintf(g.Stdout, "FAIL\t%s\n%s", release.Name, diff)
+			failed++
+			continue
+		}
+
+		fmt.Fprintf(g.Stdout, "ok\t%s\n", release.Name)
+	}
+
+	if failed > 0 {
+		return &testFailedError{failed: failed}
+	}
+
+	return nil
+}
+
+// openTestConfig loads the test config and the named releases from it.
+func openTestConfig(path string, names []string) (*TestConfig, []TestRelease, error) {
+	config, err := loadTestConfig(path)
+	if err != nil {
+		return nil, nil, &usageError{err}
+	}
+
+	releases, err := config.filter(names)
+	if err != nil {
+		return nil, nil, &usageError{err}
+	}
+
+	return config, releases, nil
+}
+
+func loadTestConfig(path string) (*TestConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read test config: %w", err)
+	}
+
+	config := new(TestConfig)
+	if err := yaml.UnmarshalWithOptions(data, config, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf("decode test config: %w", err)
+	}
+
+	seen := make(map[string]bool, len(config.Releases))
+	for _, release := range config.Releases {
+		switch {
+		case release.Name == "":
+			return nil, errors.New("invalid test config: release is missing a name")
+		case seen[release.Name]:
+			return nil, fmt.Errorf("invalid test config: duplicate release %q", release.Name)
+		case len(release.Args) == 0:
+			return nil, fmt.Errorf("invalid test config: release %q has no args", release.Name)
+		case release.Snapshot == "":
+			return nil, fmt.Errorf("invalid test config: release %q has no snapshot", release.Name)
+		}
+		seen[release.Name] = true
+	}
+
+	config.resolvePaths(filepath.Dir(path))
+
+	return config, nil
+}
+
+// resolvePaths resolves the files in the config against dir. Paths in Helm
+// arguments and post-renderer commands are left as they are, and resolved by
+// running Helm and post-renderers in dir.
+func (c *TestConfig) resolvePaths(dir string) {
+	c.dir = dir
+	c.CUEBaseDir = c.path(c.CUEBaseDir)
+	if c.CUEModuleRoot != "" {
+		c.CUEModuleRoot = c.path(c.CUEModuleRoot)
+	}
+	c.Scopes = c.scopes(c.Scopes)
+
+	for idx := range c.Releases {
+		release := &c.Releases[idx]
+		release.Values = c.paths(release.Values)
+		release.Patches = c.paths(release.Patches)
+		release.Scopes = c.scopes(release.Scopes)
+		release.Snapshot = c.path(release.Snapshot)
+	}
+}
+
+func (c *TestConfig) path(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(c.dir, path)
+}
+
+func (c *TestConfig) paths(paths []string) []string {
+	resolved := make([]string, len(paths))
+	for idx, path := range paths {
+		resolved[idx] = c.path(path)
+	}
+	return resolved
+}
+
+// scopes resolves the files of scopes given as @filename.
+func (c *TestConfig) scopes(scopes []string) []string {
+	resolved := make([]string, len(scopes))
+	for idx, scope := range scopes {
+		if filename, ok := strings.CutPrefix(scope, "@"); ok {
+			scope = "@" + c.path(filename)
+		}
+		resolved[idx] = scope
+	}
+	return resolved
+}
+
+func (c *TestConfig) filter(names []string) ([]TestRelease, error) {
+	if len(names) == 0 {
+		return c.Releases, nil
+	}
+
+	releases := make([]TestRelease, 0, len(names))
+	for _, name := range names {
+		idx := slices.IndexFunc(c.Releases, func(r TestRelease) bool { return r.Name == name })
+		if idx < 0 {
+			return nil, fmt.Errorf("release %q not found in test config", name)
+		}
+		releases = append(releases, c.Releases[idx])
+	}
+
+	return releases, nil
+}
+
+// cueFlags returns the CUE options for a release, which are added to the
+// top-level ones.
+func (c *TestConfig) cueFlags(release TestRelease) *CUEFlags {
+	return &CUEFlags{
+		Scopes:        slices.Concat(c.Scopes, release.Scopes),
+		CUEBaseDir:    c.CUEBaseDir,
+		CUEModuleRoot: c.CUEModuleRoot,
+	}
+}
+
+func (c *TestConfig) render(ctx context.Context, release TestRelease, runner konduit.Runner, logger *slog.Logger) ([]byte, error) {
+	var stdout bytes.Buffer
+	opts := []konduit.Option{
+		konduit.WithEvaluator(c.cueFlags(release).Evaluator(logger)),
+		konduit.WithRunner(runner),
+		konduit.WithStdout(&stdout),
+		konduit.WithLogger(logger.With("release", release.Name)),
+	}
+
+	if len(release.Patches) > 0 {
+		opts = append(opts, konduit.WithPatches(release.Patches))
+	}
+
+	opts = append(opts,
+		konduit.WithPostRenderersBefore(release.PostRenderersBefore...),
+		konduit.WithPostRenderersAfter(release.PostRenderersAfter...),
+	)
+
+	args := release.Args
+	if args[0] == konduit.DefaultTimoniCommand {
+		args = args[1:]
+		opts = append(opts, konduit.WithEngine(konduit.NewTimoniEngine()))
+	} else if c.HelmCommand != "" {
+		opts = append(opts, konduit.WithHelmCommand(c.HelmCommand))
+	}
+
+	k, err := konduit.New(args, release.Values, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("init: %w", err)
+	}
+
+	if err := k.Execute(ctx); err != nil {
+		return nil, fmt.Errorf("execute: %w", err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+func writeSnapshot(path string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create snapshot dir: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write snapshot: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTestConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		config  string
+		want    func(dir string) *TestConfig
+		wantErr string
+	}{
+		{
+			name: "resolves paths against the config dir",
+			config: `cueModuleRoot: ..
+scopes: [env=production, "@scope.json"]
+releases:
+  - name: web
+    args: [template, web, ./chart]
+    values: [values.cue, /abs/values.cue]
+    patches: [patches/web.yaml]
+    scopes: ["@web.json", replicas=3]
+    snapshot: snapshots/web.yaml
+`,
+			want: func(dir string) *TestConfig {
+				return &TestConfig{
+					CUEBaseDir:    dir,
+					CUEModuleRoot: filepath.Dir(dir),
+					Scopes:        []string{"env=production", "@" + filepath.Join(dir, "scope.json")},
+					Releases: []TestRelease{
+						{
+							Name:     "web",
+							Args:     []string{"template", "web", "./chart"},
+							Values:   []string{filepath.Join(dir, "values.cue"), "/abs/values.cue"},
+							Patches:  []string{filepath.Join(dir, "patches/web.yaml")},
+							Scopes:   []string{"@" + filepath.Join(dir, "web.json"), "replicas=3"},
+							Snapshot: filepath.Join(dir, "snapshots/web.yaml"),
+						},
+					},
+					dir: dir,
+				}
+			},
+		},
+		{
+			name: "keeps the module root unset",
+			config: `cueBaseDir: cue
+helmCommand: ./bin/helm
+releases:
+  - name: web
+    args: [template, web, ./chart]
+    snapshot: web.yaml
+`,
+			want: func(dir string) *TestConfig {
+				return &TestConfig{
+					CUEBaseDir:  filepath.Join(dir, "cue"),
+					Scopes:      []string{},
+					HelmCommand: "./bin/helm",
+					Releases: []TestRelease{
+						{
+							Name:     "web",
+							Args:     []string{"template", "web", "./chart"},
+							Values:   []string{},
+							Patches:  []string{},
+							Scopes:   []string{},
+							Snapshot: filepath.Join(dir, "web.yaml"),
+						},
+					},
+					dir: dir,
+				}
+			},
+		},
+		{
+			name:    "fails on unknown fields",
+			config:  "release: []\n",
+			wantErr: "decode test config",
+		},
+		{
+			name:    "fails on releases without a name",
+			config:  "releases:\n  - args: [template]\n    snapshot: web.yaml\n",
+			wantErr: "release is missing a name",
+		},
+		{
+			name: "fails on duplicate releases",
+			config: `releases:
+  - {name: web, args: [template], snapshot: a.yaml}
+  - {name: web, args: [template], snapshot: b.yaml}
+`,
+			wantErr: `duplicate release "web"`,
+		},
+		{
+			name:    "fails on releases without args",
+			config:  "releases:\n  - name: web\n    snapshot: web.yaml\n",
+			wantErr: `release "web" has no args`,
+		},
+		{
+			name:    "fails on releases without a snapshot",
+			config:  "releases:\n  - name: web\n    args: [template]\n",
+			wantErr: `release "web" has no snapshot`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := filepath.Join(t.TempDir(), "test")
+			require.NoError(t, os.Mkdir(dir, 0o755))
+			path := filepath.Join(dir, "konduit-test.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tt.config), 0o644))
+
+			config, err := loadTestConfig(path)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want(dir), config)
+		})
+	}
+}
+
+func TestTestConfig_Filter(t *testing.T) {
+	t.Parallel()
+
+	config := &TestConfig{
+		Releases: []TestRelease{{Name: "web"}, {Name: "worker"}, {Name: "cron"}},
+	}
+
+	tests := []struct {
+		name    string
+		names   []string
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "returns all releases without names",
+			want: []string{"web", "worker", "cron"},
+		},
+		{
+			name:  "returns the named releases in the given order",
+			names: []string{"cron", "web"},
+			want:  []string{"cron", "web"},
+		},
+		{
+			name:    "fails on unknown releases",
+			names:   []string{"web", "api"},
+			wantErr: `release "api" not found`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			releases, err := config.filter(tt.names)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			names := make([]string, len(releases))
+			for idx, release := range releases {
+				names[idx] = release.Name
+			}
+			assert.Equal(t, tt.want, names)
+		})
+	}
+}
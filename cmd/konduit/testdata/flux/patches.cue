@@ -0,0 +1,9 @@
+patches: [{
+	target: {kind: "Deployment", name: "web"}
+	patch: """
+		- op: add
+		  path: /metadata/labels/team
+		  value: platform
+		"""
+}]
+images: [{name: "nginx", newTag: "1.27"}]
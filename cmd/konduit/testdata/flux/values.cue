@@ -0,0 +1,2 @@
+replicas: 2
+image: {repository: "nginx", tag: null}
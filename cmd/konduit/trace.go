@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jace-ys/konduit/internal/tracing"
+)
+
+// tracer starts the spans of commands. It uses the global tracer provider, so
+// it only records spans once StartTrace has set one.
+var tracer = otel.Tracer("github.com/jace-ys/konduit/cmd/konduit")
+
+// traceShutdownTimeout bounds how long exporting the last spans may delay
+// exiting.
+const traceShutdownTimeout = 5 * time.Second
+
+// StartTrace sets up tracing if it is enabled, and starts the span of the
+// command. The span continues the trace that a parent process passed on in
+// the environment, such as Helm running the Konduit post-renderer. The returned
+// function ends the span and exports the trace.
+func (g *Globals) StartTrace(ctx context.Context, command string) (context.Context, func(error)) {
+	if g.Trace == "" {
+		return ctx, func(error) {}
+	}
+
+	exporter, err := tracing.NewExporter(g.Trace)
+	if err != nil {
+		g.Log.Warn("tracing disabled", "error", err)
+		return ctx, func(error) {}
+	}
+	// Child processes export their spans to the same destination.
+	g.Trace = exporter.Destination()
+
+	provider := tracing.NewTracerProvider(exporter,
+		attribute.String("service.name", "konduit"),
+		attribute.String("service.version", version),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		g.Log.Warn("failed to export trace", "error", err)
+	}))
+
+	ctx = tracing.FromEnviron(ctx, os.Environ())
+	ctx, span := tracer.Start(ctx, command, trace.WithAttributes(
+		attribute.Int("process.pid", os.Getpid()),
+	))
+	g.Log.Debug("tracing enabled", "destination", g.Trace, "traceID", span.SpanContext().TraceID())
+
+	return ctx, func(err error) {
+		tracing.End(span, err)
+
+		ctx, cancel := context.WithTimeout(context.Background(), traceShutdownTimeout)
+		defer cancel()
+
+		if err := provider.Shutdown(ctx); err != nil {
+			g.Log.Warn("failed to export trace", "error", err)
+		}
+	}
+}
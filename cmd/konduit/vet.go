@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	cueerrors "cuelang.org/go/cue/errors"
+
+	"github.com/jace-ys/konduit/internal/kustomize"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+type VetCmd struct {
+	Values  []string `short:"v" help:"Helm values files to be validated."`
+	Patches []string `short:"p" help:"Kustomize patches files to be validated."`
+
+	CUEFlags `embed:""`
+
+	Config   string   `short:"c" type:"existingfile" help:"Validate the releases in a test configuration file, instead of the values and patches given as flags."`
+	Releases []string `arg:"" optional:"" help:"Names of the releases in the test configuration to validate. If empty, all releases are validated."`
+}
+
+// vetProblem is a problem found in a values or patches file.
+type vetProblem struct {
+	source string
+	err    error
+}
+
+func (c *VetCmd) Validate() error {
+	switch {
+	case c.Config != "" && (len(c.Values) > 0 || len(c.Patches) > 0):
+		return errors.New("can't use --config with values or patches files")
+	case c.Config != "" && (len(c.Scopes) > 0 || c.CUEBaseDir != "" || c.CUEModuleRoot != ""):
+		return errors.New("can't use --config with --scopes, --cue-base-dir or --cue-module-root, set them in the test configuration instead")
+	case c.Config == "" && len(c.Releases) > 0:
+		return errors.New("releases can only be selected with --config")
+	case c.Config == "" && len(c.Values) == 0 && len(c.Patches) == 0:
+		return errors.New("no values or patches files provided")
+	}
+	return nil
+}
+
+func (c *VetCmd) Run(ctx context.Context, g *Globals) error {
+	if c.Config != "" {
+		return c.vetConfig(ctx, g)
+	}
+
+	ctx, cancel := c.EvalContext(ctx)
+	defer cancel()
+
+	problems := vetFiles(ctx, c.Evaluator(g.Log.Logger), c.Values, c.Patches)
+	if len(problems) > 0 {
+		printProblems(g.Stdout, problems)
+		return &konduit.EvaluationError{Err: fmt.Errorf("found %d problem(s)", len(problems))}
+	}
+
+	return nil
+}
+
+func (c *VetCmd) vetConfig(ctx context.Context, g *Globals) error {
+	config, releases, err := openTestConfig(c.Config, c.Releases)
+	if err != nil {
+		return err
+	}
+
+	var failed int
+	for _, release := range releases {
+		problems := c.vetRelease(ctx, g, config, release)
+		if len(problems) > 0 {
+			fmt.Fprintf(g.Stdout, "FAIL\t%s\n", release.Name)
+			printProblems(g.Stdout, problems)
+			failed++
+			continue
+		}
+
+		fmt.Fprintf(g.Stdout, "ok\t%s\n", release.Name)
+	}
+
+	if failed > 0 {
+		return &konduit.EvaluationError{Err: fmt.Errorf("%d of the validated releases failed", failed)}
+	}
+
+	return nil
+}
+
+// vetRelease validates the files of a release with its CUE settings from the
+// test configuration, and the evaluation and cache settings from the flags.
+func (c *VetCmd) vetRelease(ctx context.Context, g *Globals, config *TestConfig, release TestRelease) []vetProblem {
+	flags := config.cueFlags(release)
+	flags.EvalTimeout = c.EvalTimeout
+	flags.NoCache = c.NoCache
+	flags.CacheDir = c.CacheDir
+
+	ctx, cancel := flags.EvalContext(ctx)
+	defer cancel()
+
+	return vetFiles(ctx, flags.Evaluator(g.Log.Logger), release.Values, release.Patches)
+}
+
+// vetFiles evaluates values and patches the same way as rendering a release
+// would, without running Helm, and returns every problem found. Evaluated
+// values must be concrete, and patches must only use known Kustomization
+// fields.
+func vetFiles(ctx context.Context, eval konduit.Evaluator, values, patches []string) []vetProblem {
+	var problems []vetProblem
+
+	toEvaluate, static := konduit.PartitionFiles(eval, values)
+	if len(toEvaluate) > 0 {
+		if _, err := eval.Evaluate(ctx, toEvaluate); err != nil {
+			problems = append(problems, vetProblem{source: "values", err: err})
+		}
+	}
+
+	for _, file := range static {
+		content, err := os.ReadFile(file)
+		if err == nil {
+			_, err = konduit.DecodeValues(content)
+		}
+		if err != nil {
+			problems = append(problems, vetProblem{source: file, err: err})
+		}
+	}
+
+	toEvaluate, static = konduit.PartitionFiles(eval, patches)
+	if len(toEvaluate) > 0 {
+		result, err := eval.Evaluate(ctx, toEvaluate)
+		if err == nil {
+			_, err = kustomize.MakeDefinition(result.Raw)
+		}
+		if err != nil {
+			problems = append(problems, vetProblem{source: "patches", err: err})
+		}
+	}
+
+	for _, file := range static {
+		content, err := os.ReadFile(file)
+		if err == nil {
+			_, err = kustomize.MakeDefinition(content)
+		}
+		if err != nil {
+			problems = append(problems, vetProblem{source: file, err: err})
+		}
+	}
+
+	return problems
+}
+
+// printProblems prints each problem with every error it contains, as CUE
+// errors otherwise only report the first.
+func printProblems(w io.Writer, problems []vetProblem) {
+	for _, problem := range problems {
+		fmt.Fprintf(w, "%s:\n", problem.source)
+
+		details := strings.TrimRight(cueerrors.Details(problem.err, nil), "\n")
+		for _, line := range strings.Split(details, "\n") {
+			fmt.Fprintf(w, "    %s\n", line)
+		}
+	}
+}
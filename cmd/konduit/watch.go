@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/jace-ys/konduit/internal/manifest"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+const watchInterval = 500 * time.Millisecond
+
+// fileState is what is compared to detect that a watched file has changed. A
+// file that can't be read has the zero state.
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+// watch renders the release, then renders it again whenever any of the files
+// it depends on changes, printing the manifests the first time and a diff of
+// them against the previous render afterwards. Failed renders are logged and
+// don't stop watching.
+func (c *CUECmd) watch(ctx context.Context, g *Globals, args []string, opts []konduit.Option) error {
+	var previous []byte
+	var rendered bool
+
+	for {
+		var stdout bytes.Buffer
+		k, err := konduit.New(args, c.Values, slices.Concat(opts, []konduit.Option{konduit.WithStdout(&stdout)})...)
+		if err != nil {
+			return &usageError{fmt.Errorf("init: %w", err)}
+		}
+
+		files, err := k.Dependencies()
+		if err != nil {
+			g.Log.Debug("failed to find all dependencies, watching known files only", "error", err)
+		}
+		snapshot := snapshotFiles(files)
+
+		manifests, err := c.render(ctx, k, &stdout)
+		switch {
+		case ctx.Err() != nil:
+			return nil
+
+		case err != nil:
+			g.Log.Error("failed to render manifests", "error", err)
+
+		case !rendered:
+			if _, err := g.Stdout.Write(manifests); err != nil {
+				return fmt.Errorf("write manifests: %w", err)
+			}
+			previous, rendered = manifests, true
+
+		default:
+			diff, err := manifest.Diff(previous, manifests)
+			if err != nil {
+				g.Log.Error("failed to diff manifests", "error", err)
+				break
+			}
+
+			if diff == "" {
+				g.Log.Info("manifests unchanged")
+			} else if _, err := fmt.Fprint(g.Stdout, diff); err != nil {
+				return fmt.Errorf("write diff: %w", err)
+			}
+			previous = manifests
+		}
+
+		g.Log.Info("watching for changes", "files", len(files))
+		if err := waitForChange(ctx, files, snapshot); err != nil {
+			return nil
+		}
+	}
+}
+
+// render returns the manifests of the release, either rendered in-process or
+// written to stdout by Helm or Timoni.
+func (c *CUECmd) render(ctx context.Context, k *konduit.Instance, stdout *bytes.Buffer) ([]byte, error) {
+	if c.InProcess {
+		manifests, err := k.Render(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("render: %w", err)
+		}
+		return manifests, nil
+	}
+
+	if err := k.Execute(ctx); err != nil {
+		return nil, fmt.Errorf("execute: %w", err)
+	}
+
+	return stdout.Bytes(), nil
+}
+
+// waitForChange polls the files until any of them no longer matches the
+// snapshot, or the context is cancelled.
+func waitForChange(ctx context.Context, files []string, snapshot map[string]fileState) error {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if !maps.Equal(snapshotFiles(files), snapshot) {
+				return nil
+			}
+		}
+	}
+}
+
+func snapshotFiles(files []string) map[string]fileState {
+	snapshot := make(map[string]fileState, len(files))
+	for _, file := range files {
+		var state fileState
+		if info, err := os.Stat(file); err == nil {
+			state = fileState{modTime: info.ModTime(), size: info.Size()}
+		}
+		snapshot[file] = state
+	}
+	return snapshot
+}
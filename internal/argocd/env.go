@@ -0,0 +1,119 @@
+package argocd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+const (
+	EnvPrefix     = "ARGOCD_ENV_"
+	AppParameters = "ARGOCD_APP_PARAMETERS"
+)
+
+type Environment struct {
+	AppName          string
+	AppNamespace     string
+	AppRevision      string
+	AppSourcePath    string
+	AppSourceRepoURL string
+	KubeVersion      string
+	KubeAPIVersions  []string
+
+	Env        map[string]string
+	Parameters map[string]Parameter
+}
+
+type Parameter struct {
+	Name   string            `json:"name"`
+	String *string           `json:"string,omitempty"`
+	Array  []string          `json:"array,omitempty"`
+	Map    map[string]string `json:"map,omitempty"`
+}
+
+func FromEnviron(environ []string) (*Environment, error) {
+	env := &Environment{
+		Env:        make(map[string]string),
+		Parameters: make(map[string]Parameter),
+	}
+
+	for _, kv := range environ {
+		key, value, ok := strings.Cut(kv, "=")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "ARGOCD_APP_NAME":
+			env.AppName = value
+		case "ARGOCD_APP_NAMESPACE":
+			env.AppNamespace = value
+		case "ARGOCD_APP_REVISION":
+			env.AppRevision = value
+		case "ARGOCD_APP_SOURCE_PATH":
+			env.AppSourcePath = value
+		case "ARGOCD_APP_SOURCE_REPO_URL":
+			env.AppSourceRepoURL = value
+		case "KUBE_VERSION":
+			env.KubeVersion = value
+		case "KUBE_API_VERSIONS":
+			env.KubeAPIVersions = splitList(value, ",")
+		case AppParameters:
+			var params []Parameter
+			if err := json.Unmarshal([]byte(value), &params); err != nil {
+				return nil, fmt.Errorf("decode %s: %w", AppParameters, err)
+			}
+			for _, param := range params {
+				env.Parameters[param.Name] = param
+			}
+		default:
+			if name, ok := strings.CutPrefix(key, EnvPrefix); ok {
+				env.Env[name] = value
+			}
+		}
+	}
+
+	return env, nil
+}
+
+// String returns the string parameter with the given name, falling back to the
+// ARGOCD_ENV_ variable with the given key.
+func (e *Environment) String(name, key string) string {
+	if param, ok := e.Parameters[name]; ok && param.String != nil {
+		return *param.String
+	}
+	return e.Env[key]
+}
+
+// Array returns the array parameter with the given name, falling back to the
+// ARGOCD_ENV_ variable with the given key split by sep.
+func (e *Environment) Array(name, key, sep string) []string {
+	if param, ok := e.Parameters[name]; ok && param.Array != nil {
+		return param.Array
+	}
+	return splitList(e.Env[key], sep)
+}
+
+func (e *Environment) Scope() map[string]any {
+	return map[string]any{
+		"argocd": map[string]any{
+			"app": map[string]any{
+				"name":          e.AppName,
+				"namespace":     e.AppNamespace,
+				"revision":      e.AppRevision,
+				"sourcePath":    e.AppSourcePath,
+				"sourceRepoURL": e.AppSourceRepoURL,
+			},
+		},
+	}
+}
+
+func splitList(value, sep string) []string {
+	var list []string
+	for item := range strings.SplitSeq(value, sep) {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
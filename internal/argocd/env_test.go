@@ -0,0 +1,171 @@
+package argocd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/argocd"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func TestFromEnviron(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		environ []string
+		want    *argocd.Environment
+		wantErr string
+	}{
+		{
+			name:    "returns an empty environment",
+			environ: []string{"HOME=/root", "INVALID"},
+			want: &argocd.Environment{
+				Env:        map[string]string{},
+				Parameters: map[string]argocd.Parameter{},
+			},
+		},
+		{
+			name: "reads the application and cluster",
+			environ: []string{
+				"ARGOCD_APP_NAME=web",
+				"ARGOCD_APP_NAMESPACE=apps",
+				"ARGOCD_APP_REVISION=abc123",
+				"ARGOCD_APP_SOURCE_PATH=charts/web",
+				"ARGOCD_APP_SOURCE_REPO_URL=https://github.com/example/repo",
+				"KUBE_VERSION=1.31",
+				"KUBE_API_VERSIONS=v1, apps/v1,,batch/v1",
+			},
+			want: &argocd.Environment{
+				AppName:          "web",
+				AppNamespace:     "apps",
+				AppRevision:      "abc123",
+				AppSourcePath:    "charts/web",
+				AppSourceRepoURL: "https://github.com/example/repo",
+				KubeVersion:      "1.31",
+				KubeAPIVersions:  []string{"v1", "apps/v1", "batch/v1"},
+				Env:              map[string]string{},
+				Parameters:       map[string]argocd.Parameter{},
+			},
+		},
+		{
+			name: "reads plugin env and parameters",
+			environ: []string{
+				"ARGOCD_ENV_KONDUIT_VALUES=values.cue,production.cue",
+				"ARGOCD_ENV_KONDUIT_SCOPES=env=production",
+				`ARGOCD_APP_PARAMETERS=[{"name":"chart","string":"./chart"},{"name":"values","array":["values.cue"]},{"name":"labels","map":{"team":"platform"}}]`,
+			},
+			want: &argocd.Environment{
+				Env: map[string]string{
+					"KONDUIT_VALUES": "values.cue,production.cue",
+					"KONDUIT_SCOPES": "env=production",
+				},
+				Parameters: map[string]argocd.Parameter{
+					"chart":  {Name: "chart", String: ptr("./chart")},
+					"values": {Name: "values", Array: []string{"values.cue"}},
+					"labels": {Name: "labels", Map: map[string]string{"team": "platform"}},
+				},
+			},
+		},
+		{
+			name:    "fails on invalid parameters",
+			environ: []string{"ARGOCD_APP_PARAMETERS={"},
+			wantErr: "decode ARGOCD_APP_PARAMETERS",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			env, err := argocd.FromEnviron(tt.environ)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, env)
+		})
+	}
+}
+
+func TestEnvironment_Parameters(t *testing.T) {
+	t.Parallel()
+
+	env, err := argocd.FromEnviron([]string{
+		"ARGOCD_ENV_KONDUIT_CHART=./env-chart",
+		"ARGOCD_ENV_KONDUIT_VALUES=a.cue, b.yaml",
+		"ARGOCD_ENV_KONDUIT_PATCHES=patches.cue",
+		"ARGOCD_ENV_KONDUIT_CUE_BASE_DIR=cue",
+		`ARGOCD_APP_PARAMETERS=[{"name":"chart","string":"./param-chart"},{"name":"patches","array":[]},{"name":"scopes","string":"ignored"}]`,
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name  string
+		value any
+		want  any
+	}{
+		{
+			name:  "prefers string parameters",
+			value: env.String("chart", "KONDUIT_CHART"),
+			want:  "./param-chart",
+		},
+		{
+			name:  "falls back to env for strings",
+			value: env.String("cue-base-dir", "KONDUIT_CUE_BASE_DIR"),
+			want:  "cue",
+		},
+		{
+			name:  "splits env for arrays",
+			value: env.Array("values", "KONDUIT_VALUES", ","),
+			want:  []string{"a.cue", "b.yaml"},
+		},
+		{
+			name:  "prefers empty array parameters",
+			value: env.Array("patches", "KONDUIT_PATCHES", ","),
+			want:  []string{},
+		},
+		{
+			name:  "ignores parameters of another type",
+			value: env.Array("scopes", "KONDUIT_SCOPES", "\n"),
+			want:  []string(nil),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, tt.value)
+		})
+	}
+}
+
+func TestEnvironment_Scope(t *testing.T) {
+	t.Parallel()
+
+	env, err := argocd.FromEnviron([]string{
+		"ARGOCD_APP_NAME=web",
+		"ARGOCD_APP_NAMESPACE=apps",
+		"ARGOCD_APP_REVISION=abc123",
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]any{
+		"argocd": map[string]any{
+			"app": map[string]any{
+				"name":          "web",
+				"namespace":     "apps",
+				"revision":      "abc123",
+				"sourcePath":    "",
+				"sourceRepoURL": "",
+			},
+		},
+	}, env.Scope())
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const entryExt = ".yaml"
+
+type Cache struct {
+	dir string
+}
+
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// DefaultDir returns the directory used to cache evaluation results, under the
+// user's cache directory.
+func DefaultDir() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("get user cache dir: %w", err)
+	}
+	return filepath.Join(dir, "konduit", "eval"), nil
+}
+
+func (c *Cache) Dir() string {
+	return c.dir
+}
+
+// Get returns the entry for the given key, and refreshes its modification time
+// so that recently used entries survive a prune.
+func (c *Cache) Get(key string) ([]byte, bool) {
+	path := c.path(key)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return data, true
+}
+
+// Put writes the entry for the given key atomically, so concurrent readers
+// never observe a partially written entry.
+func (c *Cache) Put(key string, data []byte) error {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return fmt.Errorf("create cache dir: %w", err)
+	}
+
+	f, err := os.CreateTemp(c.dir, key+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create cache entry: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write cache entry: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close cache entry: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("commit cache entry: %w", err)
+	}
+
+	return nil
+}
+
+// Prune removes entries that have not been used for longer than maxAge, or all
+// entries if maxAge is zero. It returns the number of entries removed.
+func (c *Cache) Prune(maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(c.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	} else if err != nil {
+		return 0, fmt.Errorf("read cache dir: %w", err)
+	}
+
+	cutoff := time.Now().Add(-maxAge)
+
+	var removed int
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != entryExt {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+
+		if maxAge > 0 && info.ModTime().After(cutoff) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return removed, fmt.Errorf("remove cache entry: %w", err)
+		}
+		removed++
+	}
+
+	return removed, nil
+}
+
+func (c *Cache) path(key string) string {
+	return filepath.Join(c.dir, key+entryExt)
+}
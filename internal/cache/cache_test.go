@@ -0,0 +1,167 @@
+package cache_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/cache"
+)
+
+func TestCache_GetPut(t *testing.T) {
+	t.Parallel()
+
+	dir := filepath.Join(t.TempDir(), "eval")
+	c := cache.New(dir)
+
+	_, ok := c.Get("abc")
+	assert.False(t, ok)
+
+	require.NoError(t, c.Put("abc", []byte("greeting: hello\n")))
+	data, ok := c.Get("abc")
+	require.True(t, ok)
+	assert.Equal(t, "greeting: hello\n", string(data))
+
+	require.NoError(t, c.Put("abc", []byte("greeting: hey\n")))
+	data, ok = c.Get("abc")
+	require.True(t, ok)
+	assert.Equal(t, "greeting: hey\n", string(data))
+
+	// Temporary files are renamed into place, so none are left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "abc.yaml", entries[0].Name())
+}
+
+func TestCache_Get_RefreshesModTime(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	c := cache.New(dir)
+	require.NoError(t, c.Put("abc", []byte("greeting: hello\n")))
+
+	path := filepath.Join(dir, "abc.yaml")
+	old := time.Now().Add(-48 * time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	_, ok := c.Get("abc")
+	require.True(t, ok)
+
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.WithinDuration(t, time.Now(), info.ModTime(), time.Minute)
+}
+
+func TestCache_Put_Atomic(t *testing.T) {
+	t.Parallel()
+
+	c := cache.New(t.TempDir())
+	payloads := [][]byte{
+		bytes.Repeat([]byte("a"), 1<<20),
+		bytes.Repeat([]byte("b"), 1<<20),
+	}
+	require.NoError(t, c.Put("abc", payloads[0]))
+
+	var wg sync.WaitGroup
+	for _, payload := range payloads {
+		wg.Go(func() {
+			for range 20 {
+				assert.NoError(t, c.Put("abc", payload))
+			}
+		})
+	}
+
+	// Readers only ever see a complete entry, never a partial write.
+	for range 4 {
+		wg.Go(func() {
+			for range 50 {
+				data, ok := c.Get("abc")
+				if assert.True(t, ok) {
+					assert.True(t, bytes.Equal(data, payloads[0]) || bytes.Equal(data, payloads[1]), "read a partial entry")
+				}
+			}
+		})
+	}
+
+	wg.Wait()
+}
+
+func TestCache_Put_Error(t *testing.T) {
+	t.Parallel()
+
+	// The cache dir can't be created under a file.
+	file := filepath.Join(t.TempDir(), "file")
+	require.NoError(t, os.WriteFile(file, nil, 0o644))
+
+	err := cache.New(filepath.Join(file, "eval")).Put("abc", []byte("greeting: hello\n"))
+	assert.ErrorContains(t, err, "create cache dir")
+}
+
+func TestCache_Prune(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		maxAge      time.Duration
+		wantRemoved int
+		wantKept    []string
+	}{
+		{
+			name:        "removes every entry without a max age",
+			wantRemoved: 2,
+			wantKept:    []string{"dir", "notes.txt"},
+		},
+		{
+			name:        "removes entries unused for longer than the max age",
+			maxAge:      24 * time.Hour,
+			wantRemoved: 1,
+			wantKept:    []string{"dir", "notes.txt", "recent.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			c := cache.New(dir)
+			require.NoError(t, c.Put("recent", []byte("a: 1\n")))
+			require.NoError(t, c.Put("stale", []byte("a: 2\n")))
+			require.NoError(t, os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0o644))
+			require.NoError(t, os.Mkdir(filepath.Join(dir, "dir"), 0o755))
+
+			old := time.Now().Add(-48 * time.Hour)
+			for _, name := range []string{"stale.yaml", "notes.txt", "dir"} {
+				require.NoError(t, os.Chtimes(filepath.Join(dir, name), old, old))
+			}
+
+			removed, err := c.Prune(tt.maxAge)
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantRemoved, removed)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+
+			kept := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				kept = append(kept, entry.Name())
+			}
+			assert.Equal(t, tt.wantKept, kept)
+		})
+	}
+}
+
+func TestCache_Prune_MissingDir(t *testing.T) {
+	t.Parallel()
+
+	removed, err := cache.New(filepath.Join(t.TempDir(), "missing")).Prune(0)
+	require.NoError(t, err)
+	assert.Zero(t, removed)
+}
@@ -0,0 +1,49 @@
+package exec
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+func (o *runOptions) hasEnv() bool {
+	return len(o.env) > 0 || len(o.unsetEnv) > 0 || len(o.allowEnv) > 0
+}
+
+// environ returns the environment for a command, starting from the inherited
+// environ. Inherited variables are filtered by the allowlist and then by the
+// unset patterns, before the explicitly set variables are applied.
+func (o *runOptions) environ(environ []string) []string {
+	env := make([]string, 0, len(environ)+len(o.env))
+
+	for _, kv := range environ {
+		key, _, _ := strings.Cut(kv, "=")
+
+		if len(o.allowEnv) > 0 && !matchAny(o.allowEnv, key) {
+			continue
+		}
+
+		if matchAny(o.unsetEnv, key) {
+			continue
+		}
+
+		env = append(env, kv)
+	}
+
+	for _, kv := range o.env {
+		key, _, _ := strings.Cut(kv, "=")
+		env = slices.DeleteFunc(env, func(existing string) bool {
+			return strings.HasPrefix(existing, key+"=")
+		})
+		env = append(env, kv)
+	}
+
+	return env
+}
+
+func matchAny(patterns []string, key string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		ok, err := path.Match(pattern, key)
+		return err == nil && ok
+	})
+}
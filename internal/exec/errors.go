@@ -0,0 +1,61 @@
+package exec
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+// ExitError reports that a command ran but exited unsuccessfully.
+type ExitError struct {
+	Command string
+	Code    int
+	Signal  syscall.Signal
+	Err     error
+}
+
+func (e *ExitError) Error() string {
+	if e.Signal > 0 {
+		return fmt.Sprintf("%s terminated by signal %s", e.Command, e.Signal)
+	}
+	return fmt.Sprintf("%s exited with code %d", e.Command, e.Code)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// ExitCode returns the exit code of the command. Following shell conventions,
+// a command terminated by a signal has an exit code of 128 plus the signal
+// number.
+func (e *ExitError) ExitCode() int {
+	return e.Code
+}
+
+func newExitError(command string, err error) error {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return err
+	}
+
+	e := &ExitError{Command: command, Code: exitErr.ExitCode(), Err: err}
+
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		e.Signal = status.Signal()
+		e.Code = 128 + int(e.Signal)
+	}
+
+	return e
+}
+
+// ErrNotFound reports that the executable for a command can't be found.
+var ErrNotFound = errors.New("executable not found")
+
+type notFoundError struct {
+	err error
+}
+
+func (e *notFoundError) Error() string        { return e.err.Error() }
+func (e *notFoundError) Unwrap() error        { return e.err }
+func (e *notFoundError) Is(target error) bool { return target == ErrNotFound }
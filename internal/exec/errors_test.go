@@ -0,0 +1,83 @@
+package exec_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/exec"
+)
+
+func TestOSRunner_Errors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		command    string
+		args       []string
+		cancel     bool
+		want       *exec.ExitError
+		wantString string
+		wantIs     error
+	}{
+		{
+			name:       "reports the exit code of a failed process",
+			command:    "sh",
+			args:       []string{"-c", "exit 3"},
+			want:       &exec.ExitError{Command: "sh", Code: 3},
+			wantString: "sh exited with code 3",
+		},
+		{
+			name:       "reports 128 plus the signal of a terminated process",
+			command:    "sh",
+			args:       []string{"-c", "kill -TERM $$"},
+			want:       &exec.ExitError{Command: "sh", Code: 128 + int(syscall.SIGTERM), Signal: syscall.SIGTERM},
+			wantString: "sh terminated by signal terminated",
+		},
+		{
+			name:    "reports executables that are not found",
+			command: "konduit-test-not-found",
+			wantIs:  exec.ErrNotFound,
+		},
+		{
+			name:    "passes through errors that are not exit errors",
+			command: "sh",
+			args:    []string{"-c", "exit 0"},
+			cancel:  true,
+			wantIs:  context.Canceled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(t.Context())
+			if tt.cancel {
+				cancel()
+			}
+			defer cancel()
+
+			err := exec.NewOSRunner().Run(ctx, tt.command, tt.args, exec.WithStdout(io.Discard))
+			require.Error(t, err)
+
+			var exitErr *exec.ExitError
+			if tt.want == nil {
+				assert.ErrorIs(t, err, tt.wantIs)
+				assert.False(t, errors.As(err, &exitErr))
+				return
+			}
+
+			require.ErrorAs(t, err, &exitErr)
+			assert.Equal(t, tt.want.Command, exitErr.Command)
+			assert.Equal(t, tt.want.Code, exitErr.ExitCode())
+			assert.Equal(t, tt.want.Signal, exitErr.Signal)
+			assert.Equal(t, tt.wantString, exitErr.Error())
+		})
+	}
+}
@@ -0,0 +1,91 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"syscall"
+)
+
+// Stage is a step of a pipeline, which reads manifests from stdin and writes
+// them to stdout.
+type Stage func(ctx context.Context, stdin io.Reader, stdout io.Writer) error
+
+// Pipeline runs the stages concurrently, streaming the output of each stage to
+// the input of the next, from stdin to stdout. It waits for every stage to
+// finish, so that no process is left running, and returns the error of the
+// stage that failed first, since the stages before and after it usually fail
+// because their output was no longer read or their input was cut short.
+// Errors from writing to a closed pipe are only returned if there are no
+// others, in case the stage that stopped reading finishes last.
+func Pipeline(ctx context.Context, stdin io.Reader, stdout io.Writer, stages ...Stage) error {
+	if len(stages) == 0 {
+		_, err := io.Copy(stdout, stdin)
+		return err
+	}
+
+	var (
+		wg sync.WaitGroup
+		mu sync.Mutex
+		// errs are the errors of the stages in the order they failed.
+		errs []error
+	)
+
+	var prev *io.PipeReader
+	for idx, stage := range stages {
+		in, inPipe := stdin, prev
+		if inPipe != nil {
+			in = inPipe
+		}
+
+		out := stdout
+		var outPipe *io.PipeWriter
+		if idx < len(stages)-1 {
+			prev, outPipe = io.Pipe()
+			out = outPipe
+		}
+
+		wg.Go(func() {
+			err := stage(ctx, in, out)
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, err)
+				mu.Unlock()
+			}
+
+			// Signal the end of the output to the next stage, and stop the
+			// previous stage from blocking on output that is never read.
+			if outPipe != nil {
+				outPipe.CloseWithError(err)
+			}
+			if inPipe != nil {
+				inPipe.Close()
+			}
+		})
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if !isBrokenPipe(err) {
+			return err
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs[0]
+	}
+
+	return nil
+}
+
+// isBrokenPipe reports whether err is from writing to a pipe that was closed
+// by the stage reading from it.
+func isBrokenPipe(err error) bool {
+	var exitErr *ExitError
+	if errors.As(err, &exitErr) && exitErr.Signal == syscall.SIGPIPE {
+		return true
+	}
+	return errors.Is(err, io.ErrClosedPipe) || errors.Is(err, syscall.EPIPE)
+}
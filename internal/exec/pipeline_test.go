@@ -0,0 +1,107 @@
+package exec_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/exec"
+)
+
+func transform(f func(string) string) exec.Stage {
+	return func(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("read input: %w", err)
+		}
+		_, err = io.WriteString(stdout, f(string(data)))
+		return err
+	}
+}
+
+func passthrough(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	_, err := io.Copy(stdout, stdin)
+	return err
+}
+
+// produce writes manifests to stdout until writing fails.
+func produce(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	for {
+		if _, err := io.WriteString(stdout, "kind: ConfigMap\n---\n"); err != nil {
+			return fmt.Errorf("write output: %w", err)
+		}
+	}
+}
+
+func fail(err error) exec.Stage {
+	return func(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+		return err
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	t.Parallel()
+
+	errRender := errors.New("render failed")
+	errInvalid := errors.New("invalid manifests")
+
+	tests := []struct {
+		name    string
+		stages  []exec.Stage
+		want    string
+		wantErr error
+	}{
+		{
+			name: "copies stdin to stdout without stages",
+			want: "kind: ConfigMap\n",
+		},
+		{
+			name: "streams the output of each stage to the next",
+			stages: []exec.Stage{
+				transform(strings.ToUpper),
+				transform(func(s string) string { return "# rendered\n" + s }),
+			},
+			want: "# rendered\nKIND: CONFIGMAP\n",
+		},
+		{
+			name:    "returns the error of a first stage that failed",
+			stages:  []exec.Stage{fail(errRender), transform(strings.ToUpper)},
+			wantErr: errRender,
+		},
+		{
+			name:    "returns the error of a later stage that stopped reading",
+			stages:  []exec.Stage{produce, passthrough, fail(errInvalid)},
+			wantErr: errInvalid,
+		},
+		{
+			name:    "returns broken pipes if there are no other errors",
+			stages:  []exec.Stage{produce, fail(nil)},
+			wantErr: io.ErrClosedPipe,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var stdout bytes.Buffer
+			err := exec.Pipeline(t.Context(), strings.NewReader("kind: ConfigMap\n"), &stdout, tt.stages...)
+			if tt.wantErr != nil {
+				require.ErrorIs(t, err, tt.wantErr)
+				if !errors.Is(tt.wantErr, io.ErrClosedPipe) {
+					assert.Equal(t, tt.wantErr, err)
+				}
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package exec
+
+import (
+	"slices"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// secretFlags are the flags of Helm and Timoni whose values may hold secrets.
+var secretFlags = []string{
+	"--set",
+	"--set-string",
+	"--set-json",
+	"--set-literal",
+	"--password",
+	"--creds",
+}
+
+// RedactArgs returns a copy of args with the values of flags that may hold
+// secrets replaced, so that the args can be logged.
+func RedactArgs(args []string) []string {
+	redactedArgs := make([]string, len(args))
+	for idx, arg := range args {
+		redactedArgs[idx] = arg
+
+		if flag, _, ok := strings.Cut(arg, "="); ok && slices.Contains(secretFlags, flag) {
+			redactedArgs[idx] = flag + "=" + redacted
+		} else if idx > 0 && slices.Contains(secretFlags, args[idx-1]) {
+			redactedArgs[idx] = redacted
+		}
+	}
+	return redactedArgs
+}
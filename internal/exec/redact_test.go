@@ -0,0 +1,50 @@
+package exec_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/jace-ys/konduit/internal/exec"
+)
+
+func TestRedactArgs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{
+			name: "keeps args without secrets",
+			args: []string{"template", "my-release", "./chart", "--values", "values.yaml"},
+			want: []string{"template", "my-release", "./chart", "--values", "values.yaml"},
+		},
+		{
+			name: "redacts separate flag values",
+			args: []string{"template", "--set", "password=secret", "--set-string", "token=secret", "./chart"},
+			want: []string{"template", "--set", "[REDACTED]", "--set-string", "[REDACTED]", "./chart"},
+		},
+		{
+			name: "redacts inline flag values",
+			args: []string{"pull", "--password=secret", "--set-json={\"a\":1}", "--username=admin"},
+			want: []string{"pull", "--password=[REDACTED]", "--set-json=[REDACTED]", "--username=admin"},
+		},
+		{
+			name: "keeps flags that share a prefix",
+			args: []string{"--settings", "value"},
+			want: []string{"--settings", "value"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			args := append([]string{}, tt.args...)
+			assert.Equal(t, tt.want, exec.RedactArgs(args))
+			assert.Equal(t, tt.args, args)
+		})
+	}
+}
@@ -4,20 +4,40 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/jace-ys/konduit/internal/tracing"
 )
 
-type OSRunner struct{}
+const DefaultGracePeriod = 10 * time.Second
+
+var discardLogger = slog.New(slog.DiscardHandler)
+
+type OSRunner struct {
+	opts []RunOption
+}
 
-func NewOSRunner() *OSRunner {
-	return &OSRunner{}
+// NewOSRunner returns a runner that applies opts to every command it runs,
+// before any options passed to Run.
+func NewOSRunner(opts ...RunOption) *OSRunner {
+	return &OSRunner{opts: opts}
 }
 
 func (r *OSRunner) Run(ctx context.Context, command string, args []string, opts ...RunOption) error {
-	executable, err := exec.LookPath(command)
+	options := &runOptions{gracePeriod: DefaultGracePeriod, logger: discardLogger}
+	for _, opt := range slices.Concat(r.opts, opts) {
+		opt.apply(options)
+	}
+
+	executable, err := lookPath(command, options.dir)
 	if err != nil {
-		return fmt.Errorf("find executable %q: %w", command, err)
+		return fmt.Errorf("find executable %q: %w", command, &notFoundError{err})
 	}
 
 	cmd := exec.CommandContext(ctx, executable, args...)
@@ -25,11 +45,6 @@ func (r *OSRunner) Run(ctx context.Context, command string, args []string, opts
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
-	options := new(runOptions)
-	for _, opt := range opts {
-		opt.apply(options)
-	}
-
 	if options.stdin != nil {
 		cmd.Stdin = options.stdin
 	}
@@ -42,17 +57,48 @@ func (r *OSRunner) Run(ctx context.Context, command string, args []string, opts
 		cmd.Dir = options.dir
 	}
 
-	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("exec command: %w", err)
+	// Pass the trace context on, so that spans of a child process such as the
+	// Konduit post-renderer are part of the same trace.
+	options.env = append(tracing.Environ(ctx), options.env...)
+
+	if options.hasEnv() {
+		cmd.Env = options.environ(os.Environ())
+	}
+
+	// When ctx is cancelled, give the process a chance to shut down cleanly
+	// before it is killed, since killing Helm mid-upgrade can leave a release
+	// in a pending state.
+	if options.gracePeriod > 0 {
+		cmd.Cancel = func() error {
+			return interrupt(ctx, cmd.Process)
+		}
+		cmd.WaitDelay = options.gracePeriod
+	}
+
+	logger := options.logger
+	logger.Debug("running command", "command", executable, "args", RedactArgs(args), "dir", cmd.Dir)
+
+	start := time.Now()
+	err = cmd.Run()
+	logger.Debug("command finished", "command", executable, "duration", time.Since(start), "exitCode", cmd.ProcessState.ExitCode())
+
+	if err != nil {
+		return fmt.Errorf("exec command: %w", newExitError(command, err))
 	}
 
 	return nil
 }
 
 type runOptions struct {
-	stdin  io.Reader
-	stdout io.Writer
-	dir    string
+	stdin       io.Reader
+	stdout      io.Writer
+	dir         string
+	gracePeriod time.Duration
+	logger      *slog.Logger
+
+	env      []string
+	unsetEnv []string
+	allowEnv []string
 }
 
 type RunOption interface {
@@ -80,3 +126,66 @@ func WithDir(dir string) RunOption {
 		o.dir = dir
 	})
 }
+
+// WithLogger logs each command at debug level, with how long it took.
+func WithLogger(logger *slog.Logger) RunOption {
+	return runOptionFunc(func(o *runOptions) {
+		if logger != nil {
+			o.logger = logger
+		}
+	})
+}
+
+// WithGracePeriod sets how long to wait for a process to exit after it has
+// been signalled, before killing it. A zero grace period kills the process
+// immediately.
+func WithGracePeriod(d time.Duration) RunOption {
+	return runOptionFunc(func(o *runOptions) {
+		o.gracePeriod = d
+	})
+}
+
+// WithEnv sets environment variables for the command, in KEY=VALUE form. They
+// take precedence over inherited variables, and are set even if they are not
+// in the allowlist.
+func WithEnv(env ...string) RunOption {
+	return runOptionFunc(func(o *runOptions) {
+		o.env = append(o.env, env...)
+	})
+}
+
+// WithoutEnv removes inherited environment variables matching the given
+// patterns, which may contain * wildcards.
+func WithoutEnv(patterns ...string) RunOption {
+	return runOptionFunc(func(o *runOptions) {
+		o.unsetEnv = append(o.unsetEnv, patterns...)
+	})
+}
+
+// WithEnvAllowlist restricts inherited environment variables to those matching
+// the given patterns, which may contain * wildcards.
+func WithEnvAllowlist(patterns ...string) RunOption {
+	return runOptionFunc(func(o *runOptions) {
+		o.allowEnv = append(o.allowEnv, patterns...)
+	})
+}
+
+// Streams returns the stdin and stdout configured by opts, which are nil if
+// the command inherits them from Konduit.
+func Streams(opts ...RunOption) (stdin io.Reader, stdout io.Writer) {
+	options := new(runOptions)
+	for _, opt := range opts {
+		opt.apply(options)
+	}
+	return options.stdin, options.stdout
+}
+
+// lookPath finds the executable of command, resolving a relative path such as
+// ./bin/helm against dir, which the command runs in, rather than the current
+// directory.
+func lookPath(command, dir string) (string, error) {
+	if dir != "" && !filepath.IsAbs(command) && strings.ContainsRune(command, filepath.Separator) {
+		command = filepath.Join(dir, command)
+	}
+	return exec.LookPath(command)
+}
@@ -0,0 +1,59 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/signal"
+	"runtime"
+	"syscall"
+)
+
+// SignalCause is the cause of a context cancelled by NotifyContext.
+type SignalCause struct {
+	Signal os.Signal
+}
+
+func (c *SignalCause) Error() string {
+	return "received signal " + c.Signal.String()
+}
+
+// NotifyContext is like signal.NotifyContext, but records the received signal
+// as the cause of the cancellation so that it can be forwarded to child
+// processes. Once a signal is received, default signal handling is restored so
+// that a second signal terminates the process.
+func NotifyContext(parent context.Context, signals ...os.Signal) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(parent)
+
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, signals...)
+
+	go func() {
+		defer signal.Stop(ch)
+		select {
+		case sig := <-ch:
+			cancel(&SignalCause{Signal: sig})
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() { cancel(context.Canceled) }
+}
+
+// interruptSignal returns the signal to forward to a child process when ctx is
+// cancelled, which is the signal that cancelled it if there was one.
+func interruptSignal(ctx context.Context) os.Signal {
+	var cause *SignalCause
+	if errors.As(context.Cause(ctx), &cause) {
+		return cause.Signal
+	}
+	return syscall.SIGTERM
+}
+
+func interrupt(ctx context.Context, process *os.Process) error {
+	// Windows doesn't support sending signals to processes.
+	if runtime.GOOS == "windows" {
+		return process.Kill()
+	}
+	return process.Signal(interruptSignal(ctx))
+}
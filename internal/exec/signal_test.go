@@ -0,0 +1,171 @@
+package exec_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/exec"
+)
+
+// trapScript is a child process that reports the signals it receives to the
+// file in $1. It keeps running on signals that are ignored with "ignore" as $2.
+const trapScript = `
+if [ "$2" = "ignore" ]; then
+	trap 'echo TERM >> "$1"' TERM
+	trap 'echo INT >> "$1"' INT
+else
+	trap 'echo TERM >> "$1"; exit 0' TERM
+	trap 'echo INT >> "$1"; exit 0' INT
+fi
+touch "$1.ready"
+while :; do sleep 0.01; done
+`
+
+// startTrap runs trapScript with runner until ctx is cancelled, and returns
+// once the traps are set, along with the file the signals are reported to and
+// a channel that receives the error of the run.
+func startTrap(ctx context.Context, t *testing.T, runner *exec.OSRunner, args ...string) (string, <-chan error) {
+	t.Helper()
+
+	report := filepath.Join(t.TempDir(), "signals")
+	done := make(chan error, 1)
+	go func() {
+		done <- runner.Run(ctx, "sh", append([]string{"-c", trapScript, "sh", report}, args...), exec.WithStdout(io.Discard))
+	}()
+
+	require.Eventually(t, func() bool {
+		_, err := os.Stat(report + ".ready")
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	return report, done
+}
+
+func wait(t *testing.T, done <-chan error) error {
+	t.Helper()
+
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "process did not exit")
+		return nil
+	}
+}
+
+func TestOSRunner_Interrupt(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		cause error
+		want  string
+	}{
+		{
+			name:  "forwards the signal that cancelled the context",
+			cause: &exec.SignalCause{Signal: os.Interrupt},
+			want:  "INT\n",
+		},
+		{
+			name:  "forwards SIGTERM as a received signal",
+			cause: &exec.SignalCause{Signal: syscall.SIGTERM},
+			want:  "TERM\n",
+		},
+		{
+			name:  "sends SIGTERM when cancelled without a signal",
+			cause: context.Canceled,
+			want:  "TERM\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancelCause(t.Context())
+			defer cancel(nil)
+
+			report, done := startTrap(ctx, t, exec.NewOSRunner(exec.WithGracePeriod(5*time.Second)))
+			cancel(tt.cause)
+
+			// The process exits cleanly on the signal, but the run still
+			// fails as it was cancelled.
+			err := wait(t, done)
+			require.ErrorIs(t, err, context.Canceled)
+
+			signals, err := os.ReadFile(report)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(signals))
+		})
+	}
+}
+
+func TestOSRunner_GracePeriod(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		gracePeriod time.Duration
+		want        string
+	}{
+		{
+			name:        "kills processes that don't exit within the grace period",
+			gracePeriod: 200 * time.Millisecond,
+			want:        "TERM\n",
+		},
+		{
+			name: "kills processes immediately without a grace period",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(t.Context())
+			defer cancel()
+
+			report, done := startTrap(ctx, t, exec.NewOSRunner(exec.WithGracePeriod(tt.gracePeriod)), "ignore")
+			start := time.Now()
+			cancel()
+
+			err := wait(t, done)
+			assert.GreaterOrEqual(t, time.Since(start), tt.gracePeriod)
+
+			var exitErr *exec.ExitError
+			require.ErrorAs(t, err, &exitErr)
+			assert.Equal(t, syscall.SIGKILL, exitErr.Signal)
+
+			// The report is missing if the process was killed straight away.
+			signals, _ := os.ReadFile(report)
+			assert.Equal(t, tt.want, string(signals))
+		})
+	}
+}
+
+func TestNotifyContext(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := exec.NotifyContext(t.Context(), syscall.SIGUSR1)
+	defer cancel()
+
+	require.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGUSR1))
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "context was not cancelled")
+	}
+
+	var cause *exec.SignalCause
+	require.ErrorAs(t, context.Cause(ctx), &cause)
+	assert.Equal(t, syscall.SIGUSR1, cause.Signal)
+}
@@ -0,0 +1,149 @@
+package flux
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	kustomize "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+const (
+	HelmReleaseAPIVersion = "helm.toolkit.fluxcd.io/v2"
+	HelmReleaseKind       = "HelmRelease"
+)
+
+type HelmRelease struct {
+	APIVersion string          `yaml:"apiVersion"`
+	Kind       string          `yaml:"kind"`
+	Metadata   Metadata        `yaml:"metadata"`
+	Spec       HelmReleaseSpec `yaml:"spec"`
+}
+
+type Metadata struct {
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type HelmReleaseSpec struct {
+	Interval        string             `yaml:"interval"`
+	ReleaseName     string             `yaml:"releaseName,omitempty"`
+	TargetNamespace string             `yaml:"targetNamespace,omitempty"`
+	Chart           *HelmChartTemplate `yaml:"chart,omitempty"`
+	ChartRef        *SourceReference   `yaml:"chartRef,omitempty"`
+	Values          map[string]any     `yaml:"values,omitempty"`
+	PostRenderers   []PostRenderer     `yaml:"postRenderers,omitempty"`
+}
+
+type HelmChartTemplate struct {
+	Spec HelmChartTemplateSpec `yaml:"spec"`
+}
+
+type HelmChartTemplateSpec struct {
+	Chart     string          `yaml:"chart"`
+	Version   string          `yaml:"version,omitempty"`
+	SourceRef SourceReference `yaml:"sourceRef"`
+}
+
+type SourceReference struct {
+	Kind      string `yaml:"kind"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
+}
+
+type PostRenderer struct {
+	Kustomize *Kustomize `yaml:"kustomize,omitempty"`
+}
+
+type Kustomize struct {
+	Patches []kustomize.Patch `yaml:"patches,omitempty"`
+	Images  []kustomize.Image `yaml:"images,omitempty"`
+}
+
+// ParseSourceReference parses a reference in the format used by the Flux CLI,
+// <kind>/<name>.<namespace>, where the namespace is optional.
+func ParseSourceReference(ref string) (*SourceReference, error) {
+	kind, name, ok := strings.Cut(ref, "/")
+	if !ok || kind == "" || name == "" {
+		return nil, fmt.Errorf("invalid source reference %q, expected <kind>/<name>.<namespace>", ref)
+	}
+
+	source := &SourceReference{Kind: kind, Name: name}
+	if name, namespace, ok := strings.Cut(name, "."); ok {
+		source.Name, source.Namespace = name, namespace
+	}
+
+	return source, nil
+}
+
+// supportedFields are the kustomization fields that can be expressed by a
+// Flux post-renderer, including the deprecated patch fields that
+// Kustomization.FixKustomizationPreMarshalling converts into patches.
+var supportedFields = []string{
+	"apiVersion",
+	"kind",
+	"patches",
+	"images",
+	"patchesStrategicMerge",
+	"patchesJson6902",
+}
+
+func MakePostRenderers(patches ...[]byte) ([]PostRenderer, error) {
+	var unsupported []string
+
+	for _, patch := range patches {
+		fields := make(map[string]any)
+		if err := yaml.Unmarshal(patch, &fields); err != nil {
+			return nil, fmt.Errorf("decode patch: %w", err)
+		}
+
+		for field := range fields {
+			if !slices.Contains(supportedFields, field) && !slices.Contains(unsupported, field) {
+				unsupported = append(unsupported, field)
+			}
+		}
+	}
+
+	if len(unsupported) > 0 {
+		sort.Strings(unsupported)
+		return nil, fmt.Errorf("fields not supported by Flux post-renderers: %s", strings.Join(unsupported, ", "))
+	}
+
+	k := new(kustomize.Kustomization)
+	opts := []yaml.DecodeOption{yaml.DisallowUnknownField()}
+
+	for _, patch := range patches {
+		if err := yaml.UnmarshalWithOptions(patch, k, opts...); err != nil {
+			return nil, fmt.Errorf("decode patch: %w", err)
+		}
+	}
+	k.FixKustomization()
+
+	// Strategic merge patches are always inlined, as a path would refer to a
+	// file that Flux doesn't have.
+	if err := k.FixKustomizationPreMarshalling(filesys.MakeFsInMemory()); err != nil {
+		return nil, fmt.Errorf("convert deprecated patches: %w", err)
+	}
+
+	if len(k.Patches) == 0 && len(k.Images) == 0 {
+		return nil, nil
+	}
+
+	return []PostRenderer{{Kustomize: &Kustomize{Patches: k.Patches, Images: k.Images}}}, nil
+}
+
+func (r *HelmRelease) Validate() error {
+	if r.Metadata.Name == "" {
+		return errors.New("name is required")
+	}
+
+	if (r.Spec.Chart == nil) == (r.Spec.ChartRef == nil) {
+		return errors.New("exactly one of chart or chartRef is required")
+	}
+
+	return nil
+}
@@ -0,0 +1,179 @@
+package flux_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	kustomize "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/resid"
+
+	"github.com/jace-ys/konduit/internal/flux"
+)
+
+func TestParseSourceReference(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    *flux.SourceReference
+		wantErr string
+	}{
+		{
+			name: "parses references without a namespace",
+			ref:  "HelmRepository/bitnami",
+			want: &flux.SourceReference{Kind: "HelmRepository", Name: "bitnami"},
+		},
+		{
+			name: "parses references with a namespace",
+			ref:  "OCIRepository/nginx.flux-system",
+			want: &flux.SourceReference{Kind: "OCIRepository", Name: "nginx", Namespace: "flux-system"},
+		},
+		{
+			name:    "fails without a kind",
+			ref:     "bitnami",
+			wantErr: `invalid source reference "bitnami"`,
+		},
+		{
+			name:    "fails without a name",
+			ref:     "HelmRepository/",
+			wantErr: `invalid source reference "HelmRepository/"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ref, err := flux.ParseSourceReference(tt.ref)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, ref)
+		})
+	}
+}
+
+func TestMakePostRenderers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		patches []string
+		want    []flux.PostRenderer
+		wantErr string
+	}{
+		{
+			name: "returns nothing without patches",
+		},
+		{
+			name:    "returns nothing for empty kustomizations",
+			patches: []string{"apiVersion: kustomize.config.k8s.io/v1beta1\nkind: Kustomization\n"},
+		},
+		{
+			name: "combines patches and images of every file",
+			patches: []string{
+				"patches:\n  - target: {kind: Deployment}\n    patch: '[{\"op\": \"remove\", \"path\": \"/spec/replicas\"}]'\n",
+				"images:\n  - {name: nginx, newTag: \"1.27\"}\n",
+			},
+			want: []flux.PostRenderer{{Kustomize: &flux.Kustomize{
+				Patches: []kustomize.Patch{{Target: &kustomize.Selector{ResId: resID("Deployment")}, Patch: `[{"op": "remove", "path": "/spec/replicas"}]`}},
+				Images:  []kustomize.Image{{Name: "nginx", NewTag: "1.27"}},
+			}}},
+		},
+		{
+			name: "converts deprecated patch fields",
+			patches: []string{
+				"patchesStrategicMerge:\n  - |\n    kind: Service\n    metadata: {name: web}\n",
+				"patchesJson6902:\n  - target: {kind: Deployment}\n    patch: '[]'\n",
+			},
+			want: []flux.PostRenderer{{Kustomize: &flux.Kustomize{
+				Patches: []kustomize.Patch{
+					{Target: &kustomize.Selector{ResId: resID("Deployment")}, Patch: "[]"},
+					{Patch: "kind: Service\nmetadata: {name: web}\n"},
+				},
+			}}},
+		},
+		{
+			name:    "fails on fields Flux post-renderers don't support",
+			patches: []string{"namePrefix: prod-\ncommonLabels: {team: platform}\nimages: []\n"},
+			wantErr: "fields not supported by Flux post-renderers: commonLabels, namePrefix",
+		},
+		{
+			name:    "fails on invalid patches",
+			patches: []string{"patches: {"},
+			wantErr: "decode patch",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			patches := make([][]byte, len(tt.patches))
+			for idx, patch := range tt.patches {
+				patches[idx] = []byte(patch)
+			}
+
+			postRenderers, err := flux.MakePostRenderers(patches...)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, postRenderers)
+		})
+	}
+}
+
+func resID(kind string) resid.ResId {
+	return resid.ResId{Gvk: resid.Gvk{Kind: kind}}
+}
+
+func TestHelmRelease_Validate(t *testing.T) {
+	t.Parallel()
+
+	chart := &flux.HelmChartTemplate{Spec: flux.HelmChartTemplateSpec{Chart: "nginx"}}
+	ref := &flux.SourceReference{Kind: "OCIRepository", Name: "nginx"}
+
+	tests := []struct {
+		name    string
+		release flux.HelmRelease
+		wantErr string
+	}{
+		{
+			name:    "accepts a chart",
+			release: flux.HelmRelease{Metadata: flux.Metadata{Name: "web"}, Spec: flux.HelmReleaseSpec{Chart: chart}},
+		},
+		{
+			name:    "accepts a chart ref",
+			release: flux.HelmRelease{Metadata: flux.Metadata{Name: "web"}, Spec: flux.HelmReleaseSpec{ChartRef: ref}},
+		},
+		{
+			name:    "fails without a name",
+			release: flux.HelmRelease{Spec: flux.HelmReleaseSpec{Chart: chart}},
+			wantErr: "name is required",
+		},
+		{
+			name:    "fails with a chart and a chart ref",
+			release: flux.HelmRelease{Metadata: flux.Metadata{Name: "web"}, Spec: flux.HelmReleaseSpec{Chart: chart, ChartRef: ref}},
+			wantErr: "exactly one of chart or chartRef is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			err := tt.release.Validate()
+			if tt.wantErr != "" {
+				require.EqualError(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
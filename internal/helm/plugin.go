@@ -0,0 +1,145 @@
+package helm
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+const (
+	PluginFile              = "plugin.yaml"
+	PluginTypePostRenderer  = "postrenderer/v1"
+	PluginRuntimeSubprocess = "subprocess"
+)
+
+type Plugin struct {
+	Dir      string
+	Metadata PluginMetadata
+}
+
+type PluginMetadata struct {
+	APIVersion    string              `yaml:"apiVersion"`
+	Name          string              `yaml:"name"`
+	Type          string              `yaml:"type"`
+	Runtime       string              `yaml:"runtime"`
+	Version       string              `yaml:"version"`
+	RuntimeConfig PluginRuntimeConfig `yaml:"runtimeConfig"`
+}
+
+type PluginRuntimeConfig struct {
+	PlatformCommand []PlatformCommand `yaml:"platformCommand"`
+}
+
+type PlatformCommand struct {
+	OS      string   `yaml:"os"`
+	Arch    string   `yaml:"arch"`
+	Command string   `yaml:"command"`
+	Args    []string `yaml:"args"`
+}
+
+func PluginDirs() ([]string, error) {
+	plugins := os.Getenv("HELM_PLUGINS")
+	if plugins == "" {
+		return nil, errors.New("HELM_PLUGINS not set")
+	}
+	return filepath.SplitList(plugins), nil
+}
+
+func FindPlugin(dirs []string, name, pluginType string) (*Plugin, error) {
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		for _, entry := range entries {
+			pluginDir := filepath.Join(dir, entry.Name())
+
+			data, err := os.ReadFile(filepath.Join(pluginDir, PluginFile))
+			if err != nil {
+				continue
+			}
+
+			var metadata PluginMetadata
+			if err := yaml.Unmarshal(data, &metadata); err != nil {
+				return nil, fmt.Errorf("decode plugin %q: %w", pluginDir, err)
+			}
+
+			if metadata.Name == name && metadata.Type == pluginType {
+				return &Plugin{Dir: pluginDir, Metadata: metadata}, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("plugin %q of type %s not found", name, pluginType)
+}
+
+func (p *Plugin) Command(extraArgs []string) (string, []string, error) {
+	if p.Metadata.Runtime != PluginRuntimeSubprocess {
+		return "", nil, fmt.Errorf("unsupported plugin runtime %q", p.Metadata.Runtime)
+	}
+
+	cmd := selectPlatformCommand(p.Metadata.RuntimeConfig.PlatformCommand)
+	if cmd == nil {
+		return "", nil, errors.New("no plugin command is applicable")
+	}
+
+	expand := func(key string) string {
+		switch key {
+		case "HELM_PLUGIN_DIR":
+			return p.Dir
+		case "HELM_PLUGIN_NAME":
+			return p.Metadata.Name
+		default:
+			return os.Getenv(key)
+		}
+	}
+
+	parts := strings.Split(cmd.Command, " ")
+	command := os.Expand(parts[0], expand)
+
+	args := make([]string, 0, len(parts)-1+len(cmd.Args)+len(extraArgs))
+	for _, arg := range append(parts[1:], cmd.Args...) {
+		args = append(args, os.Expand(arg, expand))
+	}
+	args = append(args, extraArgs...)
+
+	return command, args, nil
+}
+
+// selectPlatformCommand mirrors Helm's precedence: an exact OS and arch match,
+// then an OS match without arch, then the first command without OS or arch.
+func selectPlatformCommand(cmds []PlatformCommand) *PlatformCommand {
+	var selected *PlatformCommand
+	var matchedOS bool
+
+	for i := range cmds {
+		cmd := &cmds[i]
+
+		if strings.EqualFold(cmd.OS, runtime.GOOS) && strings.EqualFold(cmd.Arch, runtime.GOARCH) {
+			return cmd
+		}
+
+		if (cmd.OS != "" && !strings.EqualFold(cmd.OS, runtime.GOOS)) || cmd.Arch != "" {
+			continue
+		}
+
+		switch {
+		case !matchedOS && cmd.OS != "":
+			selected, matchedOS = cmd, true
+		case selected == nil:
+			selected = cmd
+		}
+	}
+
+	if selected == nil || selected.Command == "" {
+		return nil
+	}
+
+	return selected
+}
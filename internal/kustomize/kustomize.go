@@ -3,16 +3,23 @@ package kustomize
 import (
 	"fmt"
 	"io"
-	"os"
 	"path/filepath"
 
 	"github.com/goccy/go-yaml"
+	"sigs.k8s.io/kustomize/api/krusty"
 	kustomize "sigs.k8s.io/kustomize/api/types"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+
+	"github.com/jace-ys/konduit/internal/workdir"
 )
 
 const (
 	ManifestsFile     = "manifests.yaml"
 	KustomizationFile = "kustomization.yaml"
+
+	// OutputFile holds the output of Kustomize when intermediate files are
+	// kept for debugging.
+	OutputFile = "kustomized.yaml"
 )
 
 func MakeDefinition(patches ...[]byte) (*kustomize.Kustomization, error) {
@@ -31,34 +38,53 @@ func MakeDefinition(patches ...[]byte) (*kustomize.Kustomization, error) {
 	return k, nil
 }
 
+// WriteKustomization writes the kustomization file to the directory, replacing
+// any written before. WriteManifests and WriteOutput do the same for their
+// files, so that a work dir can be reused.
 func WriteKustomization(dir string, k *kustomize.Kustomization) (string, error) {
-	filename := filepath.Join(dir, KustomizationFile)
+	data, err := yaml.Marshal(k)
+	if err != nil {
+		return "", fmt.Errorf("encode kustomization: %w", err)
+	}
 
-	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	return writeFile(dir, KustomizationFile, data)
+}
+
+func WriteManifests(dir string, manifests io.Reader) (string, error) {
+	data, err := io.ReadAll(manifests)
 	if err != nil {
-		return "", fmt.Errorf("create file: %w", err)
+		return "", fmt.Errorf("read manifests: %w", err)
 	}
-	defer file.Close()
 
-	if err := yaml.NewEncoder(file).Encode(k); err != nil {
-		return "", fmt.Errorf("encode to file: %w", err)
+	return writeFile(dir, ManifestsFile, data)
+}
+
+func WriteOutput(dir string, manifests []byte) (string, error) {
+	return writeFile(dir, OutputFile, manifests)
+}
+
+func writeFile(dir, name string, data []byte) (string, error) {
+	filename := filepath.Join(dir, name)
+
+	if err := workdir.WriteFile(filename, data); err != nil {
+		return "", err
 	}
 
 	return filename, nil
 }
 
-func WriteManifests(dir string, manifests io.Reader) (string, error) {
-	filename := filepath.Join(dir, ManifestsFile)
+func Build(dir string) ([]byte, error) {
+	k := krusty.MakeKustomizer(krusty.MakeDefaultOptions())
 
-	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	resources, err := k.Run(filesys.MakeFsOnDisk(), dir)
 	if err != nil {
-		return "", fmt.Errorf("create file: %w", err)
+		return nil, fmt.Errorf("run kustomizer: %w", err)
 	}
 
-	if _, err := io.Copy(file, manifests); err != nil {
-		return "", fmt.Errorf("copy to file: %w", err)
+	manifests, err := resources.AsYaml()
+	if err != nil {
+		return nil, fmt.Errorf("encode resources: %w", err)
 	}
-	defer file.Close()
 
-	return filename, nil
+	return manifests, nil
 }
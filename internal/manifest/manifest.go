@@ -0,0 +1,146 @@
+package manifest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Resource is a single Kubernetes resource from a stream of manifests.
+type Resource struct {
+	APIVersion string
+	Kind       string
+	Namespace  string
+	Name       string
+
+	// YAML is the resource re-encoded with sorted keys, so that resources can
+	// be compared regardless of formatting.
+	YAML string
+}
+
+// ID identifies the resource within a stream of manifests.
+func (r Resource) ID() string {
+	name := r.Name
+	if r.Namespace != "" {
+		name = r.Namespace + "/" + name
+	}
+	return fmt.Sprintf("%s %s %s", r.APIVersion, r.Kind, name)
+}
+
+func Parse(data []byte) ([]Resource, error) {
+	var resources []Resource
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+
+	for {
+		var doc map[string]any
+		if err := decoder.Decode(&doc); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, fmt.Errorf("decode manifest: %w", err)
+		}
+
+		if len(doc) == 0 {
+			continue
+		}
+
+		encoded, err := yaml.Marshal(doc)
+		if err != nil {
+			return nil, fmt.Errorf("encode manifest: %w", err)
+		}
+
+		metadata, _ := doc["metadata"].(map[string]any)
+		resources = append(resources, Resource{
+			APIVersion: stringField(doc, "apiVersion"),
+			Kind:       stringField(doc, "kind"),
+			Namespace:  stringField(metadata, "namespace"),
+			Name:       stringField(metadata, "name"),
+			YAML:       string(encoded),
+		})
+	}
+
+	return resources, nil
+}
+
+func stringField(m map[string]any, key string) string {
+	s, _ := m[key].(string)
+	return s
+}
+
+// Diff compares two streams of manifests resource by resource, ignoring the
+// order of resources and formatting. It returns an empty string if they are
+// equivalent, or otherwise a summary of added and removed resources followed
+// by a unified diff of each changed resource.
+func Diff(from, to []byte) (string, error) {
+	fromResources, err := Parse(from)
+	if err != nil {
+		return "", err
+	}
+
+	toResources, err := Parse(to)
+	if err != nil {
+		return "", err
+	}
+
+	before := index(fromResources)
+	after := index(toResources)
+
+	var out strings.Builder
+
+	for _, id := range sortedKeys(after) {
+		if _, ok := before[id]; !ok {
+			fmt.Fprintf(&out, "+ %s\n", id)
+		}
+	}
+
+	for _, id := range sortedKeys(before) {
+		if _, ok := after[id]; !ok {
+			fmt.Fprintf(&out, "- %s\n", id)
+		}
+	}
+
+	for _, id := range sortedKeys(after) {
+		old, ok := before[id]
+		if !ok || old == after[id] {
+			continue
+		}
+
+		diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+			A:        difflib.SplitLines(old),
+			B:        difflib.SplitLines(after[id]),
+			FromFile: id,
+			ToFile:   id,
+			Context:  3,
+		})
+		if err != nil {
+			return "", fmt.Errorf("diff %s: %w", id, err)
+		}
+
+		fmt.Fprintf(&out, "~ %s\n%s", id, diff)
+	}
+
+	return out.String(), nil
+}
+
+func index(resources []Resource) map[string]string {
+	m := make(map[string]string, len(resources))
+	for _, r := range resources {
+		m[r.ID()] += r.YAML
+	}
+	return m
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,114 @@
+package manifest_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/manifest"
+)
+
+const (
+	configMap = `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+data:
+  key: value
+`
+	service = `apiVersion: v1
+kind: Service
+metadata:
+  name: web
+`
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		from    string
+		to      string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "returns nothing for equal manifests",
+			from: configMap + "---\n" + service,
+			to:   configMap + "---\n" + service,
+		},
+		{
+			name: "ignores the order of resources and keys",
+			from: configMap + "---\n" + service,
+			to: service + "---\n" + `kind: ConfigMap
+apiVersion: v1
+data: {key: value}
+metadata: {namespace: default, name: config}
+`,
+		},
+		{
+			name: "ignores empty documents",
+			from: "---\n" + service + "---\n",
+			to:   service,
+		},
+		{
+			name: "reports added resources",
+			from: configMap,
+			to:   configMap + "---\n" + service,
+			want: "+ v1 Service web\n",
+		},
+		{
+			name: "reports removed resources",
+			from: configMap + "---\n" + service,
+			to:   service,
+			want: "- v1 ConfigMap default/config\n",
+		},
+		{
+			name: "diffs changed resources",
+			from: configMap,
+			to: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: config
+  namespace: default
+data:
+  key: changed
+`,
+			want: `~ v1 ConfigMap default/config
+--- v1 ConfigMap default/config
++++ v1 ConfigMap default/config
+@@ -1,6 +1,6 @@
+ apiVersion: v1
+ data:
+-  key: value
++  key: changed
+ kind: ConfigMap
+ metadata:
+   name: config
+`,
+		},
+		{
+			name:    "fails on invalid manifests",
+			from:    service,
+			to:      "kind: [",
+			wantErr: "decode manifest",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			diff, err := manifest.Diff([]byte(tt.from), []byte(tt.to))
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, diff)
+		})
+	}
+}
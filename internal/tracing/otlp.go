@@ -0,0 +1,322 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// TracesPath is where an OTLP/HTTP collector receives traces, if the URL of
+// the collector has no path.
+const TracesPath = "/v1/traces"
+
+// NewTracerProvider returns a tracer provider that exports spans with
+// exporter, for a resource with the given attributes. Spans are exported in
+// batches, so the provider must be shut down to export the last of them.
+func NewTracerProvider(exporter *Exporter, attrs ...attribute.KeyValue) *sdktrace.TracerProvider {
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attrs...)),
+	)
+}
+
+// Exporter exports spans as OTLP JSON, to an OTLP/HTTP collector or to a file
+// that each export is appended to as a line.
+type Exporter struct {
+	url    string
+	file   string
+	client *http.Client
+
+	mu sync.Mutex
+}
+
+// NewExporter returns an exporter to dest, which is either the http(s) URL of
+// an OTLP/HTTP collector or the path to a file.
+func NewExporter(dest string) (*Exporter, error) {
+	if dest == "" {
+		return nil, errors.New("no trace destination")
+	}
+
+	if u, err := url.Parse(dest); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		if u.Path == "" || u.Path == "/" {
+			u.Path = TracesPath
+		}
+		return &Exporter{url: u.String(), client: http.DefaultClient}, nil
+	}
+
+	// The file is resolved now, so that it is the same file when the
+	// destination is passed on to a child process with a different working
+	// directory.
+	file, err := filepath.Abs(dest)
+	if err != nil {
+		return nil, fmt.Errorf("resolve trace file: %w", err)
+	}
+
+	return &Exporter{file: file}, nil
+}
+
+// Destination returns the collector URL or absolute file path that spans are
+// exported to.
+func (e *Exporter) Destination() string {
+	if e.url != "" {
+		return e.url
+	}
+	return e.file
+}
+
+func (e *Exporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(newExportRequest(spans))
+	if err != nil {
+		return fmt.Errorf("encode spans: %w", err)
+	}
+
+	if e.url != "" {
+		return e.post(ctx, data)
+	}
+
+	return e.append(data)
+}
+
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	return nil
+}
+
+func (e *Exporter) post(ctx context.Context, data []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("send spans: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("send spans: collector responded with %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return nil
+}
+
+// append writes each export as a single line, with a single write, so that the
+// exports of processes sharing the file aren't interleaved.
+func (e *Exporter) append(data []byte) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	f, err := os.OpenFile(e.file, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("open trace file: %w", err)
+	}
+
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("write trace file: %w", err)
+	}
+
+	return f.Close()
+}
+
+// The types below encode an ExportTraceServiceRequest with the OTLP JSON
+// encoding, in which IDs are hex strings and 64-bit integers are strings.
+
+type exportRequest struct {
+	ResourceSpans []resourceSpans `json:"resourceSpans"`
+}
+
+type resourceSpans struct {
+	Resource   otlpResource `json:"resource"`
+	ScopeSpans []scopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeSpans struct {
+	Scope scope  `json:"scope"`
+	Spans []span `json:"spans"`
+}
+
+type scope struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+}
+
+type span struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	TraceState        string     `json:"traceState,omitempty"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	Name              string     `json:"name"`
+	Kind              int        `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []keyValue `json:"attributes,omitempty"`
+	Events            []event    `json:"events,omitempty"`
+	Status            status     `json:"status"`
+}
+
+type event struct {
+	TimeUnixNano string     `json:"timeUnixNano"`
+	Name         string     `json:"name"`
+	Attributes   []keyValue `json:"attributes,omitempty"`
+}
+
+type status struct {
+	Code    int    `json:"code,omitempty"`
+	Message string `json:"message,omitempty"`
+}
+
+// Status codes differ between OTLP and the OpenTelemetry API.
+const (
+	statusCodeOK    = 1
+	statusCodeError = 2
+)
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+func newExportRequest(spans []sdktrace.ReadOnlySpan) exportRequest {
+	var req exportRequest
+
+	resources := make(map[*resource.Resource]int)
+	scopes := make([]map[scope]int, 0)
+
+	for _, s := range spans {
+		ridx, ok := resources[s.Resource()]
+		if !ok {
+			req.ResourceSpans = append(req.ResourceSpans, resourceSpans{
+				Resource: otlpResource{Attributes: keyValues(s.Resource().Attributes())},
+			})
+			ridx = len(req.ResourceSpans) - 1
+			resources[s.Resource()] = ridx
+			scopes = append(scopes, make(map[scope]int))
+		}
+		rs := &req.ResourceSpans[ridx]
+
+		sc := scope{Name: s.InstrumentationScope().Name, Version: s.InstrumentationScope().Version}
+		sidx, ok := scopes[ridx][sc]
+		if !ok {
+			rs.ScopeSpans = append(rs.ScopeSpans, scopeSpans{Scope: sc})
+			sidx = len(rs.ScopeSpans) - 1
+			scopes[ridx][sc] = sidx
+		}
+
+		rs.ScopeSpans[sidx].Spans = append(rs.ScopeSpans[sidx].Spans, newSpan(s))
+	}
+
+	return req
+}
+
+func newSpan(s sdktrace.ReadOnlySpan) span {
+	out := span{
+		TraceID:           s.SpanContext().TraceID().String(),
+		SpanID:            s.SpanContext().SpanID().String(),
+		TraceState:        s.SpanContext().TraceState().String(),
+		Name:              s.Name(),
+		Kind:              int(s.SpanKind()),
+		StartTimeUnixNano: strconv.FormatInt(s.StartTime().UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.EndTime().UnixNano(), 10),
+		Attributes:        keyValues(s.Attributes()),
+	}
+
+	if s.Parent().IsValid() {
+		out.ParentSpanID = s.Parent().SpanID().String()
+	}
+
+	for _, e := range s.Events() {
+		out.Events = append(out.Events, event{
+			TimeUnixNano: strconv.FormatInt(e.Time.UnixNano(), 10),
+			Name:         e.Name,
+			Attributes:   keyValues(e.Attributes),
+		})
+	}
+
+	switch s.Status().Code {
+	case codes.Ok:
+		out.Status = status{Code: statusCodeOK}
+	case codes.Error:
+		out.Status = status{Code: statusCodeError, Message: s.Status().Description}
+	}
+
+	return out
+}
+
+func keyValues(attrs []attribute.KeyValue) []keyValue {
+	out := make([]keyValue, 0, len(attrs))
+	for _, attr := range attrs {
+		out = append(out, keyValue{Key: string(attr.Key), Value: newAnyValue(attr.Value)})
+	}
+	return out
+}
+
+func newAnyValue(v attribute.Value) anyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		b := v.AsBool()
+		return anyValue{BoolValue: &b}
+	case attribute.INT64:
+		i := strconv.FormatInt(v.AsInt64(), 10)
+		return anyValue{IntValue: &i}
+	case attribute.FLOAT64:
+		f := v.AsFloat64()
+		return anyValue{DoubleValue: &f}
+	case attribute.BOOLSLICE:
+		return arrayOf(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return arrayOf(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return arrayOf(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return arrayOf(v.AsStringSlice(), attribute.StringValue)
+	default:
+		s := v.Emit()
+		return anyValue{StringValue: &s}
+	}
+}
+
+func arrayOf[T any](values []T, value func(T) attribute.Value) anyValue {
+	array := &arrayValue{Values: make([]anyValue, 0, len(values))}
+	for _, v := range values {
+		array.Values = append(array.Values, newAnyValue(value(v)))
+	}
+	return anyValue{ArrayValue: array}
+}
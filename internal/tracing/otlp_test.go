@@ -0,0 +1,286 @@
+package tracing_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jace-ys/konduit/internal/tracing"
+)
+
+var (
+	traceID = trace.TraceID{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f, 0x10}
+	rootID  = trace.SpanID{0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01, 0x01}
+	childID = trace.SpanID{0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02, 0x02}
+	otherID = trace.SpanID{0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03, 0x03}
+)
+
+func spanContext(spanID trace.SpanID) trace.SpanContext {
+	return trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    traceID,
+		SpanID:     spanID,
+		TraceFlags: trace.FlagsSampled,
+	})
+}
+
+// testSpans are spans of two processes, the first of which has spans of two
+// instrumentation scopes.
+func testSpans() []sdktrace.ReadOnlySpan {
+	parent := resource.NewSchemaless(attribute.String("service.name", "konduit"))
+	child := resource.NewSchemaless(attribute.String("service.name", "konduit-kustomize"))
+	start := time.Unix(1700000000, 123456789)
+
+	return tracetest.SpanStubs{
+		{
+			Name:                 "execute",
+			SpanContext:          spanContext(rootID),
+			SpanKind:             trace.SpanKindInternal,
+			StartTime:            start,
+			EndTime:              start.Add(time.Second),
+			Attributes:           []attribute.KeyValue{attribute.String("konduit.engine", "helm"), attribute.Int64("konduit.files", 9007199254740993)},
+			Status:               sdktrace.Status{Code: codes.Ok},
+			Resource:             parent,
+			InstrumentationScope: instrumentation.Scope{Name: "konduit", Version: "v1.0.0"},
+		},
+		{
+			Name:        "evaluate",
+			SpanContext: spanContext(childID),
+			Parent:      spanContext(rootID),
+			SpanKind:    trace.SpanKindInternal,
+			StartTime:   start,
+			EndTime:     start.Add(time.Millisecond),
+			Attributes:  []attribute.KeyValue{attribute.StringSlice("cue.files", []string{"values.cue"}), attribute.Bool("cue.cached", false)},
+			Events: []sdktrace.Event{
+				{Name: "exception", Time: start, Attributes: []attribute.KeyValue{attribute.String("exception.message", "boom")}},
+			},
+			Status:               sdktrace.Status{Code: codes.Error, Description: "boom"},
+			Resource:             parent,
+			InstrumentationScope: instrumentation.Scope{Name: "cueval"},
+		},
+		{
+			Name:                 "kustomize",
+			SpanContext:          spanContext(otherID),
+			Parent:               spanContext(rootID),
+			SpanKind:             trace.SpanKindClient,
+			StartTime:            start,
+			EndTime:              start.Add(time.Millisecond),
+			Resource:             child,
+			InstrumentationScope: instrumentation.Scope{Name: "konduit", Version: "v1.0.0"},
+		},
+	}.Snapshots()
+}
+
+const wantRequest = `{
+  "resourceSpans": [
+    {
+      "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "konduit"}}]},
+      "scopeSpans": [
+        {
+          "scope": {"name": "konduit", "version": "v1.0.0"},
+          "spans": [
+            {
+              "traceId": "0102030405060708090a0b0c0d0e0f10",
+              "spanId": "0101010101010101",
+              "name": "execute",
+              "kind": 1,
+              "startTimeUnixNano": "1700000000123456789",
+              "endTimeUnixNano": "1700000001123456789",
+              "attributes": [
+                {"key": "konduit.engine", "value": {"stringValue": "helm"}},
+                {"key": "konduit.files", "value": {"intValue": "9007199254740993"}}
+              ],
+              "status": {"code": 1}
+            }
+          ]
+        },
+        {
+          "scope": {"name": "cueval"},
+          "spans": [
+            {
+              "traceId": "0102030405060708090a0b0c0d0e0f10",
+              "spanId": "0202020202020202",
+              "parentSpanId": "0101010101010101",
+              "name": "evaluate",
+              "kind": 1,
+              "startTimeUnixNano": "1700000000123456789",
+              "endTimeUnixNano": "1700000000124456789",
+              "attributes": [
+                {"key": "cue.files", "value": {"arrayValue": {"values": [{"stringValue": "values.cue"}]}}},
+                {"key": "cue.cached", "value": {"boolValue": false}}
+              ],
+              "events": [
+                {
+                  "timeUnixNano": "1700000000123456789",
+                  "name": "exception",
+                  "attributes": [{"key": "exception.message", "value": {"stringValue": "boom"}}]
+                }
+              ],
+              "status": {"code": 2, "message": "boom"}
+            }
+          ]
+        }
+      ]
+    },
+    {
+      "resource": {"attributes": [{"key": "service.name", "value": {"stringValue": "konduit-kustomize"}}]},
+      "scopeSpans": [
+        {
+          "scope": {"name": "konduit", "version": "v1.0.0"},
+          "spans": [
+            {
+              "traceId": "0102030405060708090a0b0c0d0e0f10",
+              "spanId": "0303030303030303",
+              "parentSpanId": "0101010101010101",
+              "name": "kustomize",
+              "kind": 3,
+              "startTimeUnixNano": "1700000000123456789",
+              "endTimeUnixNano": "1700000000124456789",
+              "status": {}
+            }
+          ]
+        }
+      ]
+    }
+  ]
+}`
+
+func TestNewExporter(t *testing.T) {
+	t.Parallel()
+
+	wd, err := os.Getwd()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name    string
+		dest    string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "adds the traces path to collector URLs",
+			dest: "http://localhost:4318",
+			want: "http://localhost:4318/v1/traces",
+		},
+		{
+			name: "keeps the path of collector URLs",
+			dest: "https://collector.example.com/otlp/v1/traces",
+			want: "https://collector.example.com/otlp/v1/traces",
+		},
+		{
+			name: "resolves relative files",
+			dest: "traces.jsonl",
+			want: filepath.Join(wd, "traces.jsonl"),
+		},
+		{
+			name:    "fails without a destination",
+			wantErr: "no trace destination",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			exporter, err := tracing.NewExporter(tt.dest)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, exporter.Destination())
+		})
+	}
+}
+
+func TestExporter_ExportSpans_File(t *testing.T) {
+	t.Parallel()
+
+	file := filepath.Join(t.TempDir(), "traces.jsonl")
+	exporter, err := tracing.NewExporter(file)
+	require.NoError(t, err)
+
+	spans := testSpans()
+	require.NoError(t, exporter.ExportSpans(t.Context(), spans))
+	require.NoError(t, exporter.ExportSpans(t.Context(), nil))
+	require.NoError(t, exporter.ExportSpans(t.Context(), spans[2:]))
+
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	require.Len(t, lines, 2)
+	assert.JSONEq(t, wantRequest, lines[0])
+	assert.Contains(t, lines[1], `"name":"kustomize"`)
+	assert.NotContains(t, lines[1], `"name":"execute"`)
+}
+
+func TestExporter_ExportSpans_HTTP(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		status  int
+		wantErr string
+	}{
+		{
+			name:   "posts spans to the collector",
+			status: http.StatusOK,
+		},
+		{
+			name:    "fails if the collector rejects spans",
+			status:  http.StatusBadRequest,
+			wantErr: "collector responded with 400 Bad Request: invalid spans",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var (
+				path, contentType string
+				body              []byte
+			)
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path = r.URL.Path
+				contentType = r.Header.Get("Content-Type")
+				body, _ = io.ReadAll(r.Body)
+
+				w.WriteHeader(tt.status)
+				if tt.status != http.StatusOK {
+					io.WriteString(w, "invalid spans\n")
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			exporter, err := tracing.NewExporter(server.URL)
+			require.NoError(t, err)
+
+			err = exporter.ExportSpans(t.Context(), testSpans())
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, tracing.TracesPath, path)
+			assert.Equal(t, "application/json", contentType)
+			assert.JSONEq(t, wantRequest, string(body))
+		})
+	}
+}
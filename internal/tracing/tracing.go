@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// propagator carries trace context between processes as W3C Trace Context, in
+// the TRACEPARENT and TRACESTATE environment variables.
+var propagator = propagation.TraceContext{}
+
+// Environ returns the environment variables that pass the trace context of ctx
+// on to a child process, or nil if ctx has no span.
+func Environ(ctx context.Context) []string {
+	carrier := propagation.MapCarrier{}
+	propagator.Inject(ctx, carrier)
+
+	env := make([]string, 0, len(carrier))
+	for _, key := range carrier.Keys() {
+		env = append(env, strings.ToUpper(key)+"="+carrier.Get(key))
+	}
+	slices.Sort(env)
+
+	return env
+}
+
+// FromEnviron returns ctx with the trace context that a parent process passed
+// on in environ, if any.
+func FromEnviron(ctx context.Context, environ []string) context.Context {
+	carrier := propagation.MapCarrier{}
+	for _, kv := range environ {
+		key, value, _ := strings.Cut(kv, "=")
+		for _, field := range propagator.Fields() {
+			if key == strings.ToUpper(field) {
+				carrier.Set(field, value)
+			}
+		}
+	}
+
+	return propagator.Extract(ctx, carrier)
+}
+
+// End records err on span if it is not nil, and ends the span.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,92 @@
+package tracing_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jace-ys/konduit/internal/tracing"
+)
+
+func TestEnviron(t *testing.T) {
+	t.Parallel()
+
+	t.Run("returns nothing without a span", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Empty(t, tracing.Environ(t.Context()))
+	})
+
+	t.Run("passes the span on to a child process", func(t *testing.T) {
+		t.Parallel()
+
+		state, err := trace.ParseTraceState("vendor=value")
+		require.NoError(t, err)
+
+		parent := spanContext(rootID).WithTraceState(state)
+		env := tracing.Environ(trace.ContextWithSpanContext(t.Context(), parent))
+		assert.Equal(t, []string{
+			"TRACEPARENT=00-0102030405060708090a0b0c0d0e0f10-0101010101010101-01",
+			"TRACESTATE=vendor=value",
+		}, env)
+
+		ctx := tracing.FromEnviron(t.Context(), append([]string{"HOME=/root"}, env...))
+		got := trace.SpanContextFromContext(ctx)
+		assert.True(t, got.IsRemote())
+		assert.Equal(t, parent.TraceID(), got.TraceID())
+		assert.Equal(t, parent.SpanID(), got.SpanID())
+		assert.Equal(t, parent.TraceFlags(), got.TraceFlags())
+		assert.Equal(t, "vendor=value", got.TraceState().String())
+	})
+
+	t.Run("ignores environments without a trace context", func(t *testing.T) {
+		t.Parallel()
+
+		ctx := tracing.FromEnviron(t.Context(), []string{"HOME=/root", "traceparent=lowercase"})
+		assert.False(t, trace.SpanContextFromContext(ctx).IsValid())
+	})
+}
+
+func TestEnd(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus sdktrace.Status
+		wantEvents int
+	}{
+		{
+			name: "ends spans without errors",
+		},
+		{
+			name:       "records errors",
+			err:        errors.New("boom"),
+			wantStatus: sdktrace.Status{Code: codes.Error, Description: "boom"},
+			wantEvents: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			_, span := provider.Tracer("test").Start(t.Context(), "test")
+			tracing.End(span, tt.err)
+
+			ended := recorder.Ended()
+			require.Len(t, ended, 1)
+			assert.Equal(t, tt.wantStatus, ended[0].Status())
+			assert.Len(t, ended[0].Events(), tt.wantEvents)
+		})
+	}
+}
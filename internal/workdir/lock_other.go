@@ -0,0 +1,14 @@
+//go:build !unix && !windows
+
+package workdir
+
+import "os"
+
+// Work dirs aren't locked on platforms without file locking.
+func tryLock(f *os.File) (bool, error) {
+	return true, nil
+}
+
+func unlockFile(f *os.File) error {
+	return nil
+}
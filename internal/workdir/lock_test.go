@@ -0,0 +1,99 @@
+//go:build unix || windows
+
+package workdir_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/workdir"
+)
+
+// lockAsync takes the lock in the background, returning a channel that
+// receives the unlock function once the lock is taken.
+func lockAsync(ctx context.Context, t *testing.T, dir string) <-chan func() {
+	t.Helper()
+
+	locked := make(chan func(), 1)
+	go func() {
+		unlock, err := workdir.Lock(ctx, dir)
+		if assert.NoError(t, err) {
+			locked <- unlock
+		}
+	}()
+	return locked
+}
+
+func TestLock_Contention(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	unlock, err := workdir.Lock(t.Context(), dir)
+	require.NoError(t, err)
+	assert.FileExists(t, filepath.Join(dir, workdir.LockFile))
+
+	locked := lockAsync(t.Context(), t, dir)
+	select {
+	case <-locked:
+		require.FailNow(t, "lock was taken by two holders")
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	unlock()
+
+	select {
+	case unlock := <-locked:
+		assert.FileExists(t, filepath.Join(dir, workdir.LockFile))
+		unlock()
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "lock was not taken after it was released")
+	}
+
+	assert.NoFileExists(t, filepath.Join(dir, workdir.LockFile))
+}
+
+func TestLock_RemovedLockFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	unlock, err := workdir.Lock(t.Context(), dir)
+	require.NoError(t, err)
+
+	// The waiting holder opened the lock file that is removed on unlock, so it
+	// must take the lock again on a new one for the lock to still exclude a
+	// third holder.
+	locked := lockAsync(t.Context(), t, dir)
+	time.Sleep(200 * time.Millisecond)
+	unlock()
+
+	var unlockSecond func()
+	select {
+	case unlockSecond = <-locked:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "lock was not taken after it was released")
+	}
+	defer unlockSecond()
+
+	ctx, cancel := context.WithTimeout(t.Context(), 300*time.Millisecond)
+	defer cancel()
+
+	_, err = workdir.Lock(ctx, dir)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "wait for lock")
+}
+
+func TestLock_Errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := workdir.Lock(t.Context(), filepath.Join(t.TempDir(), "missing"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+	assert.ErrorContains(t, err, "open lock file")
+}
@@ -0,0 +1,128 @@
+// Package workdir manages directories that intermediate files are written to,
+// which may be shared by several invocations of Konduit.
+package workdir
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockFile is taken by an invocation for as long as it uses a work dir.
+const LockFile = ".konduit.lock"
+
+const lockRetryInterval = 100 * time.Millisecond
+
+// Lock takes an exclusive lock on the directory, waiting until any other
+// invocation using it has finished or the context is cancelled. The lock is
+// held until unlock is called, which removes the lock file, or the process
+// exits.
+func Lock(ctx context.Context, dir string) (unlock func(), err error) {
+	name := filepath.Join(dir, LockFile)
+	for {
+		f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("open lock file: %w", err)
+		}
+
+		if err := waitLock(ctx, f); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("lock %s: %w", dir, err)
+		}
+
+		// The previous holder may have removed the lock file while we were
+		// waiting on it, in which case the lock must be taken on a new one.
+		if !isFile(f, name) {
+			_ = unlockFile(f)
+			f.Close()
+			continue
+		}
+
+		return func() {
+			os.Remove(name)
+			_ = unlockFile(f)
+			f.Close()
+		}, nil
+	}
+}
+
+func waitLock(ctx context.Context, f *os.File) error {
+	for {
+		ok, err := tryLock(f)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("wait for lock: %w", ctx.Err())
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// isFile reports whether f is still the named file.
+func isFile(f *os.File, name string) bool {
+	opened, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	current, err := os.Stat(name)
+	if err != nil {
+		return false
+	}
+
+	return os.SameFile(opened, current)
+}
+
+// WriteFile replaces the named file atomically, so that a file written by a
+// previous invocation is never observed partially overwritten.
+func WriteFile(name string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(name), "."+filepath.Base(name)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("create file: %w", err)
+	}
+	defer os.Remove(f.Name())
+
+	// CreateTemp creates files only readable by their owner, unlike
+	// os.WriteFile.
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return fmt.Errorf("set file mode: %w", err)
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("write file: %w", err)
+	}
+
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("close file: %w", err)
+	}
+
+	if err := os.Rename(f.Name(), name); err != nil {
+		return fmt.Errorf("replace file: %w", err)
+	}
+
+	return nil
+}
+
+// Remove removes the named files from the directory, ignoring those that
+// don't exist.
+func Remove(dir string, names ...string) error {
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("remove %s: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package workdir_test
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/workdir"
+)
+
+func TestWriteFile(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	name := filepath.Join(dir, "evaluated.yaml")
+
+	require.NoError(t, workdir.WriteFile(name, []byte("greeting: hello\n")))
+	require.NoError(t, workdir.WriteFile(name, []byte("greeting: hey\n")))
+
+	data, err := os.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, "greeting: hey\n", string(data))
+
+	if runtime.GOOS != "windows" {
+		info, err := os.Stat(name)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+	}
+
+	// The temporary file is renamed into place, so none are left behind.
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, "evaluated.yaml", entries[0].Name())
+}
+
+func TestWriteFile_Error(t *testing.T) {
+	t.Parallel()
+
+	err := workdir.WriteFile(filepath.Join(t.TempDir(), "missing", "evaluated.yaml"), nil)
+	assert.ErrorContains(t, err, "create file")
+}
+
+func TestRemove(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		files    []string
+		remove   []string
+		wantKept []string
+		wantErr  string
+	}{
+		{
+			name:     "removes the named files",
+			files:    []string{"evaluated.yaml", "manifests.yaml", "chart.tgz"},
+			remove:   []string{"evaluated.yaml", "manifests.yaml"},
+			wantKept: []string{"chart.tgz"},
+		},
+		{
+			name:     "ignores files that don't exist",
+			files:    []string{"chart.tgz"},
+			remove:   []string{"evaluated.yaml"},
+			wantKept: []string{"chart.tgz"},
+		},
+		{
+			name:     "fails on files that can't be removed",
+			files:    []string{"charts/chart.tgz"},
+			remove:   []string{"charts"},
+			wantKept: []string{"charts"},
+			wantErr:  "remove charts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			dir := t.TempDir()
+			for _, file := range tt.files {
+				require.NoError(t, os.MkdirAll(filepath.Dir(filepath.Join(dir, file)), 0o755))
+				require.NoError(t, os.WriteFile(filepath.Join(dir, file), nil, 0o644))
+			}
+
+			err := workdir.Remove(dir, tt.remove...)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+
+			kept := make([]string, 0, len(entries))
+			for _, entry := range entries {
+				kept = append(kept, entry.Name())
+			}
+			assert.Equal(t, tt.wantKept, kept)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package cuegen
+
+import (
+	"errors"
+	"fmt"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/encoding/yaml"
+)
+
+// Document is a YAML document of values to import.
+type Document struct {
+	Filename string
+	Data     []byte
+}
+
+// Import converts YAML documents of values into CUE files of the given
+// package. Values that are the same in every document are factored out into a
+// shared base, and the file for each document only holds the values that
+// differ, so that unifying the base with any of them gives back the original
+// values. The base is nil if no values are shared. Comments are kept, taking
+// those of the first document for shared values.
+func Import(pkg string, docs ...Document) (base []byte, files [][]byte, err error) {
+	if len(docs) == 0 {
+		return nil, nil, errors.New("no documents to import")
+	}
+
+	structs := make([][]ast.Decl, len(docs))
+	for idx, doc := range docs {
+		f, err := yaml.Extract(doc.Filename, doc.Data)
+		if err != nil {
+			return nil, nil, fmt.Errorf("extract YAML: %w", err)
+		}
+
+		structs[idx], err = mappingDecls(doc.Filename, f)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	common, deltas := factor(cuecontext.New(), structs)
+
+	if len(common) > 0 {
+		base, err = formatFile(pkg, common)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	files = make([][]byte, len(deltas))
+	for idx, delta := range deltas {
+		files[idx], err = formatFile(pkg, delta)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return base, files, nil
+}
+
+// factor splits the fields of each struct into those that are common to all of
+// them, and those that remain for each struct. Fields that are structs in every
+// document are factored recursively.
+func factor(ctx *cue.Context, structs [][]ast.Decl) (common []ast.Decl, deltas [][]ast.Decl) {
+	drop := make(map[*ast.Field]bool)
+
+	for _, decl := range structs[0] {
+		field, ok := decl.(*ast.Field)
+		if !ok {
+			continue
+		}
+
+		fields := []*ast.Field{field}
+		for _, other := range structs[1:] {
+			if f := lookupField(other, field.Label); f != nil {
+				fields = append(fields, f)
+			}
+		}
+
+		if len(fields) != len(structs) {
+			continue
+		}
+
+		if nested, ok := structValues(fields); ok {
+			nestedCommon, nestedDeltas := factor(ctx, nested)
+			if len(nestedCommon) == 0 {
+				continue
+			}
+
+			// The comments of the first document move to the shared field, so
+			// they aren't repeated in every document.
+			shared := &ast.Field{Label: field.Label, Value: &ast.StructLit{Elts: nestedCommon}}
+			ast.SetComments(shared, ast.Comments(field))
+			common = append(common, shared)
+
+			for idx, f := range fields {
+				ast.SetComments(f, nil)
+				f.Value.(*ast.StructLit).Elts = nestedDeltas[idx]
+				drop[f] = len(nestedDeltas[idx]) == 0
+			}
+			continue
+		}
+
+		if equalValues(ctx, fields) {
+			common = append(common, field)
+			for _, f := range fields {
+				drop[f] = true
+			}
+		}
+	}
+
+	deltas = make([][]ast.Decl, len(structs))
+	for idx, decls := range structs {
+		for _, decl := range decls {
+			if field, ok := decl.(*ast.Field); ok && drop[field] {
+				continue
+			}
+			deltas[idx] = append(deltas[idx], decl)
+		}
+	}
+
+	return common, deltas
+}
+
+func lookupField(decls []ast.Decl, label ast.Label) *ast.Field {
+	name, _, _ := ast.LabelName(label)
+	for _, decl := range decls {
+		if field, ok := decl.(*ast.Field); ok {
+			if other, _, _ := ast.LabelName(field.Label); other == name {
+				return field
+			}
+		}
+	}
+	return nil
+}
+
+// structValues returns the fields of each value if they are all structs, and
+// at least one of them isn't empty.
+func structValues(fields []*ast.Field) ([][]ast.Decl, bool) {
+	var empty int
+	nested := make([][]ast.Decl, len(fields))
+	for idx, field := range fields {
+		s, ok := field.Value.(*ast.StructLit)
+		if !ok {
+			return nil, false
+		}
+		if len(s.Elts) == 0 {
+			empty++
+		}
+		nested[idx] = s.Elts
+	}
+	return nested, empty < len(fields)
+}
+
+func equalValues(ctx *cue.Context, fields []*ast.Field) bool {
+	first := ctx.BuildExpr(fields[0].Value)
+	for _, field := range fields[1:] {
+		if !first.Equals(ctx.BuildExpr(field.Value)) {
+			return false
+		}
+	}
+	return true
+}
+
+func formatFile(pkg string, decls []ast.Decl) ([]byte, error) {
+	f := &ast.File{Decls: append([]ast.Decl{&ast.Package{Name: ast.NewIdent(pkg)}}, decls...)}
+
+	src, err := format.Node(f, format.Simplify())
+	if err != nil {
+		return nil, fmt.Errorf("format CUE: %w", err)
+	}
+
+	return src, nil
+}
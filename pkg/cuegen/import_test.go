@@ -0,0 +1,150 @@
+package cuegen_test
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/encoding/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/cuegen"
+)
+
+func TestImport(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		docs      []string
+		wantBase  string
+		wantFiles []string
+		wantErr   string
+	}{
+		{
+			name: "factors out shared values",
+			docs: []string{
+				`# Number of replicas
+replicaCount: 1
+# The image
+image:
+  repository: nginx # the repository
+  tag: "1.0"
+ports: [80]
+debug: true
+`,
+				`replicaCount: 3
+image:
+  repository: nginx
+  tag: "2.0"
+ports: [80]
+`,
+			},
+			wantBase: `package values
+
+// The image
+image: repository: "nginx" // the repository
+ports: [80]
+`,
+			wantFiles: []string{
+				`package values
+
+// Number of replicas
+replicaCount: 1
+image: tag: "1.0"
+debug: true
+`,
+				`package values
+
+replicaCount: 3
+image: tag: "2.0"
+`,
+			},
+		},
+		{
+			name: "keeps all values when none are shared",
+			docs: []string{"count: 1\n", "count: 3\n"},
+			wantFiles: []string{
+				"package values\n\ncount: 1\n",
+				"package values\n\ncount: 3\n",
+			},
+		},
+		{
+			name: "moves all values to the base for a single document",
+			docs: []string{"count: 1\n"},
+			wantBase: `package values
+
+count: 1
+`,
+			wantFiles: []string{"package values\n"},
+		},
+		{
+			name:    "returns error when values are not a mapping",
+			docs:    []string{"count: 1\n", "- a\n"},
+			wantErr: "expected a YAML mapping",
+		},
+		{
+			name:    "returns error when no documents provided",
+			wantErr: "no documents to import",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			docs := make([]cuegen.Document, len(tt.docs))
+			for idx, doc := range tt.docs {
+				docs[idx] = cuegen.Document{Filename: "values.yaml", Data: []byte(doc)}
+			}
+
+			base, files, err := cuegen.Import("values", docs...)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantBase, string(base))
+
+			got := make([]string, len(files))
+			for idx, f := range files {
+				got[idx] = string(f)
+			}
+			assert.Equal(t, tt.wantFiles, got)
+		})
+	}
+}
+
+func TestImport_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	docs := []string{
+		"image:\n  repository: nginx\n  tag: \"1.0\"\nresources: {}\ningress:\n  enabled: false\n  hosts: [a.local]\n",
+		"image:\n  repository: nginx\n  tag: \"2.0\"\nresources:\n  limits:\n    cpu: 1\ningress:\n  enabled: false\n  hosts: [b.local]\n",
+		"image:\n  repository: nginx\nresources: {}\ningress:\n  enabled: true\n  hosts: [a.local]\n",
+	}
+
+	input := make([]cuegen.Document, len(docs))
+	for idx, doc := range docs {
+		input[idx] = cuegen.Document{Filename: "values.yaml", Data: []byte(doc)}
+	}
+
+	base, files, err := cuegen.Import("values", input...)
+	require.NoError(t, err)
+
+	ctx := cuecontext.New()
+	for idx, doc := range docs {
+		f, err := yaml.Extract("values.yaml", []byte(doc))
+		require.NoError(t, err)
+
+		var want map[string]any
+		require.NoError(t, ctx.BuildFile(f).Decode(&want))
+
+		var got map[string]any
+		v := ctx.CompileBytes(base).Unify(ctx.CompileBytes(files[idx]))
+		require.NoError(t, v.Decode(&got))
+
+		assert.Equal(t, want, got)
+	}
+}
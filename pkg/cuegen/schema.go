@@ -0,0 +1,140 @@
+package cuegen
+
+import (
+	"fmt"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/cue/format"
+	"cuelang.org/go/cue/token"
+	"cuelang.org/go/encoding/yaml"
+)
+
+const SchemaDefinition = "#Values"
+
+// Schema derives a CUE schema from a YAML document of default values, such as
+// a Helm chart's values.yaml. Each value is constrained to its type and
+// defaults to its original value, and comments are kept as documentation.
+// Structs with fields are closed, so unknown keys are reported, while empty
+// structs, lists and null values accept anything.
+func Schema(filename string, data []byte, pkg string) ([]byte, error) {
+	f, err := yaml.Extract(filename, data)
+	if err != nil {
+		return nil, fmt.Errorf("extract YAML: %w", err)
+	}
+
+	decls, err := mappingDecls(filename, f)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := schemaStruct(decls)
+
+	field := &ast.Field{Label: ast.NewIdent(SchemaDefinition), Value: schema}
+	ast.AddComment(field, &ast.CommentGroup{
+		Doc: true,
+		List: []*ast.Comment{
+			{Text: fmt.Sprintf("// %s is the schema of the chart's values, derived from its defaults.", SchemaDefinition)},
+		},
+	})
+
+	out := &ast.File{
+		Decls: []ast.Decl{
+			&ast.Package{Name: ast.NewIdent(pkg)},
+			field,
+		},
+	}
+
+	src, err := format.Node(out, format.Simplify())
+	if err != nil {
+		return nil, fmt.Errorf("format schema: %w", err)
+	}
+
+	return src, nil
+}
+
+// mappingDecls returns the fields of a YAML mapping. An empty document has no
+// fields, and any other document is rejected.
+func mappingDecls(filename string, f *ast.File) ([]ast.Decl, error) {
+	if len(f.Decls) != 1 {
+		return f.Decls, nil
+	}
+
+	embed, ok := f.Decls[0].(*ast.EmbedDecl)
+	if !ok {
+		return f.Decls, nil
+	}
+
+	if s, ok := embed.Expr.(*ast.StructLit); ok {
+		return s.Elts, nil
+	}
+
+	// An empty document is extracted as an expression that defaults to null.
+	if v, _ := cuecontext.New().BuildExpr(embed.Expr).Default(); v.IsNull() {
+		return nil, nil
+	}
+
+	return nil, fmt.Errorf("%s: expected a YAML mapping", filename)
+}
+
+func schemaStruct(decls []ast.Decl) *ast.StructLit {
+	s := &ast.StructLit{Lbrace: token.Blank.Pos(), Rbrace: token.Newline.Pos()}
+
+	for _, decl := range decls {
+		if field, ok := decl.(*ast.Field); ok {
+			field.Value = schemaExpr(field.Value)
+		}
+		s.Elts = append(s.Elts, decl)
+	}
+
+	if len(s.Elts) == 0 {
+		s.Lbrace, s.Rbrace = token.NoSpace.Pos(), token.NoSpace.Pos()
+		s.Elts = []ast.Decl{&ast.Ellipsis{Ellipsis: token.NoSpace.Pos()}}
+	}
+
+	return s
+}
+
+func schemaExpr(expr ast.Expr) ast.Expr {
+	switch x := expr.(type) {
+	case *ast.StructLit:
+		return schemaStruct(x.Elts)
+	case *ast.ListLit:
+		return withDefault(ast.NewList(&ast.Ellipsis{}), x)
+	case *ast.UnaryExpr:
+		if typ := basicType(x.X); typ != nil {
+			return withDefault(typ, x)
+		}
+	case *ast.BasicLit:
+		if typ := basicType(x); typ != nil {
+			return withDefault(typ, x)
+		}
+	}
+	return expr
+}
+
+func basicType(expr ast.Expr) ast.Expr {
+	lit, ok := expr.(*ast.BasicLit)
+	if !ok {
+		return nil
+	}
+
+	switch lit.Kind {
+	case token.STRING:
+		return ast.NewIdent("string")
+	case token.INT:
+		return ast.NewIdent("int")
+	case token.FLOAT:
+		return ast.NewIdent("number")
+	case token.TRUE, token.FALSE:
+		return ast.NewIdent("bool")
+	case token.NULL:
+		return ast.NewIdent("_")
+	default:
+		return nil
+	}
+}
+
+func withDefault(typ, value ast.Expr) ast.Expr {
+	return ast.NewBinExpr(token.OR, typ, &ast.UnaryExpr{Op: token.MUL, X: value})
+}
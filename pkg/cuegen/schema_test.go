@@ -0,0 +1,107 @@
+package cuegen_test
+
+import (
+	"testing"
+
+	"cuelang.org/go/cue"
+	"cuelang.org/go/cue/cuecontext"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/cuegen"
+)
+
+func TestSchema(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		values  string
+		want    string
+		wantErr string
+	}{
+		{
+			name: "constrains values to their types with defaults",
+			values: `# Number of replicas
+replicaCount: 1
+image:
+  repository: nginx # the image
+  pullPolicy: IfNotPresent
+ratio: 0.5
+enabled: false
+nothing: null
+resources: {}
+tolerations: []
+`,
+			want: `package values
+
+// #Values is the schema of the chart's values, derived from its defaults.
+#Values: {
+	// Number of replicas
+	replicaCount: int | *1
+	image: {
+		repository: string | *"nginx" // the image
+		pullPolicy: string | *"IfNotPresent"
+	}
+	ratio:   number | *0.5
+	enabled: bool | *false
+	nothing: _ | *null
+	resources: {...}
+	tolerations: [...] | *[]
+}
+`,
+		},
+		{
+			name:   "allows anything for empty values",
+			values: "",
+			want: `package values
+
+// #Values is the schema of the chart's values, derived from its defaults.
+#Values: {...}
+`,
+		},
+		{
+			name:    "returns error when values are not a mapping",
+			values:  "- a\n- b\n",
+			wantErr: "expected a YAML mapping",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			schema, err := cuegen.Schema("values.yaml", []byte(tt.values), "values")
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(schema))
+		})
+	}
+}
+
+func TestSchema_Defaults(t *testing.T) {
+	t.Parallel()
+
+	values := "replicaCount: 1\nimage:\n  tag: \"\"\nhosts:\n  - host: a.local\n"
+
+	schema, err := cuegen.Schema("values.yaml", []byte(values), "values")
+	require.NoError(t, err)
+
+	v := cuecontext.New().CompileBytes(schema).LookupPath(cue.ParsePath(cuegen.SchemaDefinition))
+	require.NoError(t, v.Err())
+
+	var got map[string]any
+	require.NoError(t, v.Decode(&got))
+	assert.Equal(t, map[string]any{
+		"replicaCount": int64(1),
+		"image":        map[string]any{"tag": ""},
+		"hosts":        []any{map[string]any{"host": "a.local"}},
+	}, got)
+
+	assert.Error(t, v.FillPath(cue.ParsePath("replicaCount"), "two").Validate())
+	assert.Error(t, v.FillPath(cue.ParsePath("image.unknown"), "x").Validate())
+}
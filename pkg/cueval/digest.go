@@ -0,0 +1,280 @@
+package cueval
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	"cuelang.org/go/cue/load"
+	"cuelang.org/go/cue/parser"
+	"cuelang.org/go/mod/modfile"
+)
+
+// Digest returns a hash of every file that may contribute to evaluating the
+// given files, including transitive imports, along with the scope path and
+// scope data. It doesn't load the instance: it follows the imports of each
+// file to the packages of the main module, and relies on cue.mod/module.cue
+// pinning the versions of other modules, so it only costs reading the files.
+// It may hash files that don't contribute, which can only cause cache misses.
+func (e *Evaluator) Digest(files []string) (string, error) {
+	if len(files) == 0 {
+		return "", errors.New("no CUE files provided")
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "scope %q\n", e.scope)
+
+	for _, scope := range e.scopes {
+		data := []byte(scope)
+		if filename, ok := strings.CutPrefix(scope, "@"); ok {
+			var err error
+			data, err = os.ReadFile(filename)
+			if err != nil {
+				return "", fmt.Errorf("read scope file: %w", err)
+			}
+		}
+		if err := writeEntry(h, "scopes", data); err != nil {
+			return "", err
+		}
+	}
+
+	d, err := newDigester(e.loader)
+	if err != nil {
+		return "", err
+	}
+
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return "", fmt.Errorf("resolve file: %w", err)
+		}
+		if err := d.addFile(abs); err != nil {
+			return "", err
+		}
+	}
+
+	if d.root != "" {
+		if err := d.addFile(filepath.Join(d.root, "cue.mod", "module.cue")); err != nil {
+			return "", err
+		}
+	}
+
+	filenames := slices.Sorted(maps.Keys(d.files))
+	for _, filename := range filenames {
+		if err := writeEntry(h, filename, d.files[filename]); err != nil {
+			return "", err
+		}
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// digester collects the files that a digest is computed from.
+type digester struct {
+	root   string
+	module string
+	files  map[string][]byte
+	dirs   map[string]bool
+}
+
+// newDigester finds the module root the same way as load.Instances, and the
+// path of the module without its major version.
+func newDigester(loader *load.Config) (*digester, error) {
+	d := &digester{files: make(map[string][]byte), dirs: make(map[string]bool)}
+
+	dir, err := filepath.Abs(loader.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve load dir: %w", err)
+	}
+
+	switch {
+	case loader.ModuleRoot == "":
+		for ; ; dir = filepath.Dir(dir) {
+			if info, err := os.Stat(filepath.Join(dir, "cue.mod")); err == nil && info.IsDir() {
+				d.root = dir
+				break
+			}
+			if dir == filepath.Dir(dir) {
+				break
+			}
+		}
+	case filepath.IsAbs(loader.ModuleRoot):
+		d.root = filepath.Clean(loader.ModuleRoot)
+	default:
+		d.root = filepath.Join(dir, loader.ModuleRoot)
+	}
+
+	if d.root == "" {
+		return d, nil
+	}
+
+	filename := filepath.Join(d.root, "cue.mod", "module.cue")
+	data, err := os.ReadFile(filename)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("read module file: %w", err)
+	}
+
+	mod, err := modfile.ParseNonStrict(data, filename)
+	if err != nil {
+		return nil, fmt.Errorf("parse module file: %w", err)
+	}
+	d.module = ast.ParseImportPath(mod.Module).Path
+
+	return d, nil
+}
+
+// addFile adds a file and the packages it imports.
+func (d *digester) addFile(filename string) error {
+	if _, ok := d.files[filename]; ok {
+		return nil
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return fmt.Errorf("read file: %w", err)
+	}
+	d.files[filename] = data
+
+	// A file that can't be parsed is hashed all the same, and evaluating it
+	// reports the error.
+	f, err := parser.ParseFile(filename, data, parser.ImportsOnly)
+	if err != nil {
+		return nil
+	}
+
+	for _, spec := range f.Imports {
+		path, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		for _, dir := range d.importDirs(ast.ParseImportPath(path).Path) {
+			if err := d.addPackage(dir); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// importDirs returns the directories that an import path may be loaded from
+// within the module root. Packages of other modules are only found in
+// cue.mod/gen, cue.mod/pkg and cue.mod/usr, and otherwise are fetched from a
+// registry at the version pinned by cue.mod/module.cue.
+func (d *digester) importDirs(path string) []string {
+	if d.root == "" {
+		return nil
+	}
+
+	if d.module != "" && (path == d.module || strings.HasPrefix(path, d.module+"/")) {
+		rel := strings.TrimPrefix(path, d.module)
+		return []string{filepath.Join(d.root, filepath.FromSlash(rel))}
+	}
+
+	var dirs []string
+	for _, vendor := range []string{"gen", "pkg", "usr"} {
+		dir := filepath.Join(d.root, "cue.mod", vendor, filepath.FromSlash(path))
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// addPackage adds the CUE files of a package directory, and of its parent
+// directories up to the module root, since CUE unifies files of the same
+// package in parent directories.
+func (d *digester) addPackage(dir string) error {
+	for ; dir == d.root || strings.HasPrefix(dir, d.root+string(filepath.Separator)); dir = filepath.Dir(dir) {
+		if d.dirs[dir] {
+			return nil
+		}
+		d.dirs[dir] = true
+
+		entries, err := os.ReadDir(dir)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("read package dir: %w", err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() || filepath.Ext(entry.Name()) != ".cue" {
+				continue
+			}
+			if err := d.addFile(filepath.Join(dir, entry.Name())); err != nil {
+				return err
+			}
+		}
+
+		if dir == d.root {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// Dependencies loads the instance for the given files and returns every file
+// that contributes to its evaluation, including transitive imports and scope
+// files, sorted and without duplicates.
+func (e *Evaluator) Dependencies(files []string) ([]string, error) {
+	if len(files) == 0 {
+		return nil, errors.New("no CUE files provided")
+	}
+
+	inst, err := e.Load(files)
+	if err != nil {
+		return nil, err
+	}
+
+	deps := instanceFiles(inst, make(map[*build.Instance]bool))
+	for _, scope := range e.scopes {
+		if filename, ok := strings.CutPrefix(scope, "@"); ok {
+			deps = append(deps, filename)
+		}
+	}
+
+	sort.Strings(deps)
+	return slices.Compact(deps), nil
+}
+
+func instanceFiles(inst *build.Instance, visited map[*build.Instance]bool) []string {
+	if visited[inst] {
+		return nil
+	}
+	visited[inst] = true
+
+	var filenames []string
+	for _, f := range inst.BuildFiles {
+		filenames = append(filenames, f.Filename)
+	}
+
+	for _, imp := range inst.Imports {
+		filenames = append(filenames, instanceFiles(imp, visited)...)
+	}
+
+	return filenames
+}
+
+func writeEntry(w io.Writer, name string, data []byte) error {
+	sum := sha256.Sum256(data)
+	if _, err := fmt.Fprintf(w, "%s %x\n", name, sum); err != nil {
+		return fmt.Errorf("hash %s: %w", name, err)
+	}
+	return nil
+}
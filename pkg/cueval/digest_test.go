@@ -0,0 +1,156 @@
+package cueval_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/cueval"
+)
+
+func TestEvaluator_Digest(t *testing.T) {
+	t.Parallel()
+
+	digest := func(t *testing.T, files []string, opts ...cueval.Option) string {
+		t.Helper()
+		d, err := cueval.NewEvaluator(opts...).Digest(files)
+		require.NoError(t, err)
+		return d
+	}
+
+	t.Run("is stable for unchanged inputs", func(t *testing.T) {
+		t.Parallel()
+
+		files := []string{"testdata/simple.cue"}
+		assert.Equal(t, digest(t, files), digest(t, files))
+	})
+
+	t.Run("changes with file contents", func(t *testing.T) {
+		t.Parallel()
+
+		file := filepath.Join(t.TempDir(), "values.cue")
+		require.NoError(t, os.WriteFile(file, []byte(`foo: "bar"`), 0o644))
+		before := digest(t, []string{file})
+
+		require.NoError(t, os.WriteFile(file, []byte(`foo: "baz"`), 0o644))
+		assert.NotEqual(t, before, digest(t, []string{file}))
+	})
+
+	t.Run("changes with scopes", func(t *testing.T) {
+		t.Parallel()
+
+		files := []string{"testdata/scope.cue"}
+		assert.NotEqual(t,
+			digest(t, files, cueval.WithScopes(`{"foo": "one"}`)),
+			digest(t, files, cueval.WithScopes(`{"foo": "two"}`)),
+		)
+	})
+
+	t.Run("changes with scope file contents", func(t *testing.T) {
+		t.Parallel()
+
+		scope := filepath.Join(t.TempDir(), "scope.yaml")
+		files := []string{"testdata/scope.cue"}
+
+		require.NoError(t, os.WriteFile(scope, []byte("foo: one"), 0o644))
+		before := digest(t, files, cueval.WithScopes("@"+scope))
+
+		require.NoError(t, os.WriteFile(scope, []byte("foo: two"), 0o644))
+		assert.NotEqual(t, before, digest(t, files, cueval.WithScopes("@"+scope)))
+	})
+
+	t.Run("follows imports within the module", func(t *testing.T) {
+		t.Parallel()
+
+		root := writeModule(t, map[string]string{
+			"values.cue":    "package values\n\nimport \"example.com/app/lib\"\n\nfoo: lib.#Name\n",
+			"lib/lib.cue":   "package lib\n\n#Name: \"one\"\n",
+			"other/one.cue": "package other\n",
+		})
+		files := []string{filepath.Join(root, "values.cue")}
+		before := digest(t, files, cueval.WithLoadDir(root))
+
+		require.NoError(t, os.WriteFile(filepath.Join(root, "other", "one.cue"), []byte("package other\n\nfoo: 1\n"), 0o644))
+		assert.Equal(t, before, digest(t, files, cueval.WithLoadDir(root)))
+
+		require.NoError(t, os.WriteFile(filepath.Join(root, "lib", "lib.cue"), []byte("package lib\n\n#Name: \"two\"\n"), 0o644))
+		assert.NotEqual(t, before, digest(t, files, cueval.WithLoadDir(root)))
+	})
+
+	t.Run("changes with the module file", func(t *testing.T) {
+		t.Parallel()
+
+		root := writeModule(t, map[string]string{"values.cue": "foo: 1\n"})
+		files := []string{filepath.Join(root, "values.cue")}
+		before := digest(t, files, cueval.WithLoadModuleRoot(root))
+
+		module := filepath.Join(root, "cue.mod", "module.cue")
+		data, err := os.ReadFile(module)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(module, append(data, "deps: \"cue.dev/x/k8s.io@v0\": v: \"v0.6.0\"\n"...), 0o644))
+		assert.NotEqual(t, before, digest(t, files, cueval.WithLoadModuleRoot(root)))
+	})
+
+	t.Run("doesn't fetch imported modules", func(t *testing.T) {
+		t.Parallel()
+
+		root := writeModule(t, map[string]string{
+			"values.cue": "package values\n\nimport corev1 \"cue.dev/x/k8s.io/api/core/v1\"\n\nsvc: corev1.#Service\n",
+		})
+		eval := cueval.NewEvaluator(cueval.WithLoadDir(root))
+
+		_, err := eval.Digest([]string{filepath.Join(root, "values.cue")})
+		require.NoError(t, err)
+	})
+
+	t.Run("returns error when no files provided", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := cueval.NewEvaluator().Digest(nil)
+		assert.ErrorContains(t, err, "no CUE files provided")
+	})
+}
+
+func TestEvaluator_Dependencies(t *testing.T) {
+	t.Parallel()
+
+	t.Run("includes loaded files and scope files", func(t *testing.T) {
+		t.Parallel()
+
+		eval := cueval.NewEvaluator(cueval.WithScopes("@testdata/scope.yaml", `{"bar": "two"}`))
+		deps, err := eval.Dependencies([]string{"testdata/scope.cue"})
+		require.NoError(t, err)
+
+		require.Len(t, deps, 2)
+		assert.True(t, filepath.IsAbs(deps[0]))
+		assert.Equal(t, "scope.cue", filepath.Base(deps[0]))
+		assert.Equal(t, "testdata/scope.yaml", deps[1])
+	})
+
+	t.Run("returns error when no files provided", func(t *testing.T) {
+		t.Parallel()
+
+		_, err := cueval.NewEvaluator().Dependencies(nil)
+		assert.ErrorContains(t, err, "no CUE files provided")
+	})
+}
+
+// writeModule writes files to a new CUE module named example.com/app, and
+// returns its root.
+func writeModule(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	root := t.TempDir()
+	files["cue.mod/module.cue"] = "module: \"example.com/app@v0\"\nlanguage: version: \"v0.15.0\"\n"
+
+	for name, data := range files {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o755))
+		require.NoError(t, os.WriteFile(filename, []byte(data), 0o644))
+	}
+
+	return root
+}
@@ -1,11 +1,13 @@
 package cueval
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"cuelang.org/go/cue"
 	"cuelang.org/go/cue/build"
@@ -13,32 +15,100 @@ import (
 	cueerrors "cuelang.org/go/cue/errors"
 	"cuelang.org/go/cue/load"
 	"cuelang.org/go/encoding/yaml"
+	"cuelang.org/go/mod/modconfig"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/jace-ys/konduit/internal/tracing"
 )
 
-func Eval(files []string, opts ...Option) (cue.Value, error) {
-	return NewEvaluator(opts...).Eval(files)
+func Eval(ctx context.Context, files []string, opts ...Option) (cue.Value, error) {
+	return NewEvaluator(opts...).Eval(ctx, files)
 }
 
-func (e *Evaluator) Eval(files []string) (cue.Value, error) {
+// Eval loads and builds the given files. CUE itself can't be interrupted, so
+// when ctx is done Eval returns immediately with the context's error, and the
+// evaluation stops in the background at the end of its current stage: loading,
+// building scopes, building the instance or unifying it with the scopes.
+func (e *Evaluator) Eval(ctx context.Context, files []string) (cue.Value, error) {
 	if len(files) == 0 {
 		return cue.Value{}, errors.New("no CUE files provided")
 	}
 
-	inst, err := e.Load(files)
+	if ctx.Err() != nil {
+		return cue.Value{}, fmt.Errorf("evaluation cancelled: %w", context.Cause(ctx))
+	}
+
+	type result struct {
+		value cue.Value
+		err   error
+	}
+
+	done := make(chan result, 1)
+	go func() {
+		v, err := e.eval(ctx, files)
+		done <- result{value: v, err: err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		return cue.Value{}, fmt.Errorf("evaluation cancelled: %w", context.Cause(ctx))
+	case r := <-done:
+		return r.value, r.err
+	}
+}
+
+func (e *Evaluator) eval(ctx context.Context, files []string) (cue.Value, error) {
+	start := time.Now()
+
+	inst, err := e.load(ctx, files)
 	if err != nil {
 		return cue.Value{}, err
 	}
 
-	v, err := e.Build(inst)
+	if err := context.Cause(ctx); err != nil {
+		return cue.Value{}, err
+	}
+
+	v, err := e.build(ctx, inst)
 	if err != nil {
 		return cue.Value{}, err
 	}
 
+	e.logger.Debug("evaluated CUE files", "files", files, "duration", time.Since(start))
 	return v, nil
 }
 
+// tracerName is the instrumentation scope of the spans of an Evaluator.
+const tracerName = "github.com/jace-ys/konduit/pkg/cueval"
+
+func (e *Evaluator) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	provider := e.tracer
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
 func (e *Evaluator) Load(files []string) (*build.Instance, error) {
+	return e.load(context.Background(), files)
+}
+
+func (e *Evaluator) load(ctx context.Context, files []string) (_ *build.Instance, err error) {
+	if e.shared != nil {
+		if inst, ok := e.shared.instance(ctx, e, files); ok {
+			return inst, nil
+		}
+	}
+
+	_, span := e.startSpan(ctx, "cue load", attribute.StringSlice("konduit.files", files))
+	defer func() { tracing.End(span, err) }()
+
 	resolved := e.tryResolvePaths(files)
+	e.registryOnce.Do(e.initRegistry)
+
+	e.logger.Debug("loading CUE instance", "files", resolved, "dir", e.loader.Dir, "moduleRoot", e.loader.ModuleRoot)
 
 	instances := load.Instances(resolved, e.loader)
 	if len(instances) != 1 {
@@ -50,9 +120,29 @@ func (e *Evaluator) Load(files []string) (*build.Instance, error) {
 		return nil, fmt.Errorf("load instance: %s", cueerrors.Details(inst.Err, nil))
 	}
 
+	e.logger.Debug("loaded CUE instance", "module", inst.Module, "root", inst.Root, "imports", len(inst.Imports))
+
 	return inst, nil
 }
 
+// initRegistry creates the module registry up front, so that concurrent loads
+// share its module cache instead of each resolving dependencies separately.
+// The loader is otherwise only read by load.Instances, so it is safe for
+// concurrent use once the registry is set.
+func (e *Evaluator) initRegistry() {
+	if e.loader.Registry != nil {
+		return
+	}
+
+	registry, err := modconfig.NewRegistry(&modconfig.Config{Env: e.loader.Env})
+	if err != nil {
+		// Leave the registry unset, so the error is reported by load.Instances
+		// only if it needs to resolve modules.
+		return
+	}
+	e.loader.Registry = registry
+}
+
 func (e *Evaluator) tryResolvePaths(files []string) []string {
 	if e.loader.Dir == "" {
 		return files
@@ -84,21 +174,40 @@ func (e *Evaluator) tryResolvePaths(files []string) []string {
 }
 
 func (e *Evaluator) Build(inst *build.Instance) (cue.Value, error) {
-	ctx := cuecontext.New()
+	return e.build(context.Background(), inst)
+}
+
+func (e *Evaluator) build(ctx context.Context, inst *build.Instance) (_ cue.Value, err error) {
+	ctx, span := e.startSpan(ctx, "cue build")
+	defer func() { tracing.End(span, err) }()
+
+	cueCtx := cuecontext.New()
 
-	vScopes, err := e.buildScopes(ctx)
+	vScopes, err := e.buildScopes(ctx, cueCtx)
 	if err != nil {
 		return cue.Value{}, err
 	}
 
-	v := ctx.BuildInstance(inst, cue.Scope(vScopes))
-	if v.Err() != nil {
-		return cue.Value{}, fmt.Errorf("build instance: %w", v.Err())
+	if err := context.Cause(ctx); err != nil {
+		return cue.Value{}, err
 	}
 
-	v = v.Unify(vScopes)
+	v := e.buildInstance(cueCtx, inst, vScopes)
 	if v.Err() != nil {
-		return cue.Value{}, fmt.Errorf("unify instance with scopes: %w", v.Err())
+		return cue.Value{}, fmt.Errorf("build instance: %w", allErrors(v))
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		return cue.Value{}, err
+	}
+
+	v, err = e.unifyScopes(ctx, v, vScopes)
+	if err != nil {
+		return cue.Value{}, err
+	}
+
+	if err := context.Cause(ctx); err != nil {
+		return cue.Value{}, err
 	}
 
 	if err := v.Validate(cue.Concrete(true)); err != nil {
@@ -108,15 +217,51 @@ func (e *Evaluator) Build(inst *build.Instance) (cue.Value, error) {
 	return v, nil
 }
 
-func (e *Evaluator) buildScopes(ctx *cue.Context) (cue.Value, error) {
-	vAllScopes := ctx.CompileString("{}")
+// buildInstance builds inst without evaluating it. Instances of a shared load
+// are built one at a time, as they share syntax trees that building mutates.
+func (e *Evaluator) buildInstance(cueCtx *cue.Context, inst *build.Instance, vScopes cue.Value) cue.Value {
+	if e.shared != nil {
+		e.shared.buildMu.Lock()
+		defer e.shared.buildMu.Unlock()
+	}
+	return cueCtx.BuildInstance(inst, cue.Scope(vScopes))
+}
+
+// allErrors returns every error in v, rather than only the first one that
+// v.Err reports.
+func allErrors(v cue.Value) error {
+	if err := v.Validate(); err != nil {
+		return err
+	}
+	return v.Err()
+}
+
+// unifyScopes unifies the built instance with the scopes, so that fields
+// defined by scopes are concrete.
+func (e *Evaluator) unifyScopes(ctx context.Context, v, vScopes cue.Value) (_ cue.Value, err error) {
+	_, span := e.startSpan(ctx, "cue unify scopes", attribute.Int("konduit.scopes", len(e.scopes)))
+	defer func() { tracing.End(span, err) }()
+
+	v = v.Unify(vScopes)
+	if v.Err() != nil {
+		return cue.Value{}, fmt.Errorf("unify instance with scopes: %w", allErrors(v))
+	}
+
+	return v, nil
+}
+
+func (e *Evaluator) buildScopes(ctx context.Context, cueCtx *cue.Context) (_ cue.Value, err error) {
+	_, span := e.startSpan(ctx, "cue build scopes", attribute.Int("konduit.scopes", len(e.scopes)))
+	defer func() { tracing.End(span, err) }()
+
+	vAllScopes := cueCtx.CompileString("{}")
 
 	for _, scope := range e.scopes {
 		if scope == "" {
 			continue
 		}
 
-		vScope, err := e.parseScope(ctx, scope)
+		vScope, err := e.parseScope(cueCtx, scope)
 		if err != nil {
 			return cue.Value{}, err
 		}
@@ -125,11 +270,22 @@ func (e *Evaluator) buildScopes(ctx *cue.Context) (cue.Value, error) {
 		if vAllScopes.Err() != nil {
 			return cue.Value{}, fmt.Errorf("unify scopes: %w", vAllScopes.Err())
 		}
+
+		e.logger.Debug("merged scope", "scope", scopeSource(scope), "path", e.scope)
 	}
 
 	return vAllScopes, nil
 }
 
+// scopeSource describes a scope for logging, without logging inline data that
+// might be sensitive.
+func scopeSource(scope string) string {
+	if filename, ok := strings.CutPrefix(scope, "@"); ok {
+		return filename
+	}
+	return "inline"
+}
+
 func (e *Evaluator) parseScope(ctx *cue.Context, scope string) (cue.Value, error) {
 	var data []byte
 
@@ -1,11 +1,17 @@
 package cueval_test
 
 import (
+	"context"
+	"sync"
 	"testing"
+	"time"
 
+	cueerrors "cuelang.org/go/cue/errors"
 	"cuelang.org/go/encoding/yaml"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"github.com/jace-ys/konduit/pkg/cueval"
 )
@@ -112,7 +118,7 @@ func TestEval(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			t.Parallel()
 
-			value, err := cueval.Eval(tt.files, tt.opts...)
+			value, err := cueval.Eval(t.Context(), tt.files, tt.opts...)
 			if tt.wantErr != "" {
 				require.Error(t, err)
 				assert.ErrorContains(t, err, tt.wantErr)
@@ -126,3 +132,112 @@ func TestEval(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluator_EvalConcurrent(t *testing.T) {
+	t.Parallel()
+
+	eval := cueval.NewEvaluator(cueval.WithScopes(`{"foo": "one", "bar": 1}`))
+	files := [][]string{
+		{"testdata/simple.cue"},
+		{"testdata/scope.cue"},
+		{"testdata/simple.cue"},
+		{"testdata/scope.cue"},
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, len(files))
+	for idx, f := range files {
+		wg.Go(func() {
+			_, errs[idx] = eval.Eval(t.Context(), f)
+		})
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		require.NoError(t, err)
+	}
+}
+
+func TestEvaluator_EvalCancelled(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	_, err := cueval.NewEvaluator().Eval(ctx, []string{"testdata/simple.cue"})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.ErrorContains(t, err, "evaluation cancelled")
+}
+
+func TestEvaluator_EvalCancelledStopsInBackground(t *testing.T) {
+	t.Parallel()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	recorder := &cancellingRecorder{SpanRecorder: tracetest.NewSpanRecorder(), cancelOn: "cue build scopes", cancel: cancel}
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	_, err := cueval.NewEvaluator(cueval.WithTracerProvider(provider)).Eval(ctx, []string{"testdata/simple.cue"})
+	require.ErrorIs(t, err, context.Canceled)
+
+	ended := func(name string) func() bool {
+		return func() bool {
+			for _, span := range recorder.Ended() {
+				if span.Name() == name {
+					return true
+				}
+			}
+			return false
+		}
+	}
+	require.Eventually(t, ended("cue build"), time.Second, 10*time.Millisecond)
+	assert.False(t, ended("cue unify scopes")())
+}
+
+// cancellingRecorder cancels a context when a span with a given name starts.
+type cancellingRecorder struct {
+	*tracetest.SpanRecorder
+	cancelOn string
+	cancel   context.CancelFunc
+}
+
+func (r *cancellingRecorder) OnStart(ctx context.Context, s sdktrace.ReadWriteSpan) {
+	r.SpanRecorder.OnStart(ctx, s)
+	if s.Name() == r.cancelOn {
+		r.cancel()
+	}
+}
+
+func TestEval_ReportsAllErrors(t *testing.T) {
+	t.Parallel()
+
+	_, err := cueval.Eval(t.Context(), []string{"testdata/constrained.cue"},
+		cueval.WithScopes(`{"foo": "not-an-int", "bar": "different"}`),
+	)
+	require.Error(t, err)
+
+	details := cueerrors.Details(err, nil)
+	assert.Contains(t, details, "foo: conflicting values")
+	assert.Contains(t, details, "bar: conflicting values")
+}
+
+func TestEvaluator_WithTracerProvider(t *testing.T) {
+	t.Parallel()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	ctx, parent := provider.Tracer("test").Start(t.Context(), "evaluate")
+	_, err := cueval.NewEvaluator(
+		cueval.WithTracerProvider(provider),
+		cueval.WithScopes(`{"foo": 1, "bar": "fixed"}`),
+	).Eval(ctx, []string{"testdata/constrained.cue"})
+	parent.End()
+	require.NoError(t, err)
+
+	names := make([]string, 0)
+	for _, span := range recorder.Ended() {
+		assert.Equal(t, parent.SpanContext().TraceID(), span.SpanContext().TraceID())
+		names = append(names, span.Name())
+	}
+	assert.ElementsMatch(t, []string{"cue load", "cue build scopes", "cue unify scopes", "cue build", "evaluate"}, names)
+}
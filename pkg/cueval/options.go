@@ -1,7 +1,11 @@
 package cueval
 
 import (
+	"log/slog"
+	"sync"
+
 	"cuelang.org/go/cue/load"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const DefaultScopePath = "#Konduit"
@@ -10,12 +14,18 @@ type Evaluator struct {
 	loader *load.Config
 	scope  string
 	scopes []string
+	logger *slog.Logger
+	tracer trace.TracerProvider
+
+	registryOnce sync.Once
+	shared       *sharedLoad
 }
 
 func NewEvaluator(opts ...Option) *Evaluator {
 	e := &Evaluator{
 		loader: &load.Config{},
 		scope:  DefaultScopePath,
+		logger: slog.New(slog.DiscardHandler),
 	}
 
 	for _, opt := range opts {
@@ -51,6 +61,25 @@ func WithScopePath(path string) Option {
 	})
 }
 
+// WithLogger logs how files are loaded and scopes are merged at debug level.
+func WithLogger(logger *slog.Logger) Option {
+	return OptionFunc(func(o *Evaluator) {
+		if logger != nil {
+			o.logger = logger
+		}
+	})
+}
+
+// WithTracerProvider traces loading, building and unifying with scopes with
+// spans from provider, instead of the global tracer provider.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return OptionFunc(func(o *Evaluator) {
+		if provider != nil {
+			o.tracer = provider
+		}
+	})
+}
+
 func WithScopes(scopes ...string) Option {
 	return OptionFunc(func(o *Evaluator) {
 		o.scopes = append(o.scopes, scopes...)
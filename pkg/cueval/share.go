@@ -0,0 +1,160 @@
+package cueval
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+
+	"cuelang.org/go/cue/ast"
+	"cuelang.org/go/cue/build"
+	cueerrors "cuelang.org/go/cue/errors"
+	"cuelang.org/go/cue/load"
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/jace-ys/konduit/internal/tracing"
+)
+
+// Share returns an Evaluator that evaluates files the same way as e, but loads
+// every one of fileSets together the first time any of them is evaluated, so
+// that evaluating the sets concurrently loads the module and the packages they
+// import only once. Each set is still built in its own CUE context. Files that
+// aren't one of fileSets, or that can't be loaded together, are loaded on
+// their own.
+func (e *Evaluator) Share(fileSets ...[]string) *Evaluator {
+	e.registryOnce.Do(e.initRegistry)
+
+	return &Evaluator{
+		loader: e.loader,
+		scope:  e.scope,
+		scopes: e.scopes,
+		logger: e.logger,
+		tracer: e.tracer,
+		shared: &sharedLoad{fileSets: fileSets},
+	}
+}
+
+type sharedLoad struct {
+	fileSets [][]string
+
+	once      sync.Once
+	instances []*build.Instance
+
+	// buildMu serialises building instances, as building resolves references
+	// in the syntax trees that the instances of a shared load have in common.
+	buildMu sync.Mutex
+}
+
+// instance returns the instance of files from the shared load, or false if
+// files aren't one of the shared sets or the shared load failed.
+func (s *sharedLoad) instance(ctx context.Context, e *Evaluator, files []string) (*build.Instance, bool) {
+	idx := slices.IndexFunc(s.fileSets, func(set []string) bool {
+		return slices.Equal(set, files)
+	})
+	if idx < 0 {
+		return nil, false
+	}
+
+	s.once.Do(func() {
+		instances, err := e.loadShared(ctx, s.fileSets)
+		if err != nil {
+			e.logger.Debug("loading CUE files separately", "error", err)
+			return
+		}
+		s.instances = instances
+	})
+
+	if s.instances == nil {
+		return nil, false
+	}
+	return s.instances[idx], true
+}
+
+// loadShared loads the files of every set as one instance, and splits it into
+// an instance for each set that shares the imports of the others.
+func (e *Evaluator) loadShared(ctx context.Context, fileSets [][]string) (_ []*build.Instance, err error) {
+	dir, err := filepath.Abs(e.loader.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("resolve load dir: %w", err)
+	}
+
+	// Loaded files are named by their absolute path, which splitting the
+	// instance matches against.
+	absolute := make([][]string, len(fileSets))
+	var files []string
+	for idx, set := range fileSets {
+		for _, file := range e.tryResolvePaths(set) {
+			if !filepath.IsAbs(file) {
+				file = filepath.Join(dir, file)
+			}
+			file = filepath.Clean(file)
+			absolute[idx] = append(absolute[idx], file)
+			if !slices.Contains(files, file) {
+				files = append(files, file)
+			}
+		}
+	}
+
+	_, span := e.startSpan(ctx, "cue load", attribute.StringSlice("konduit.files", files))
+	defer func() { tracing.End(span, err) }()
+
+	e.logger.Debug("loading shared CUE instance", "files", files, "dir", e.loader.Dir, "moduleRoot", e.loader.ModuleRoot)
+
+	// Sets may belong to different packages, which only a load of every
+	// package accepts. Each set is checked to be of one package when split.
+	loader := *e.loader
+	loader.Package = "*"
+
+	instances := load.Instances(files, &loader)
+	if len(instances) != 1 {
+		return nil, fmt.Errorf("expected 1 instance, got %d", len(instances))
+	}
+
+	inst := instances[0]
+	if inst.Err != nil {
+		return nil, fmt.Errorf("load instance: %s", cueerrors.Details(inst.Err, nil))
+	}
+
+	split := make([]*build.Instance, len(fileSets))
+	for idx, set := range absolute {
+		split[idx], err = splitInstance(inst, set)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	e.logger.Debug("loaded shared CUE instance", "module", inst.Module, "root", inst.Root, "imports", len(inst.Imports))
+
+	return split, nil
+}
+
+// splitInstance returns a copy of inst with only the given files, which must
+// be absolute. The files keep their order in inst, as when loaded on their own.
+func splitInstance(inst *build.Instance, files []string) (*build.Instance, error) {
+	part := *inst
+	part.Files = make([]*ast.File, 0, len(files))
+	part.PkgName = ""
+
+	for _, f := range inst.Files {
+		if !slices.Contains(files, f.Filename) {
+			continue
+		}
+
+		if pkg := f.PackageName(); pkg != "" {
+			if part.PkgName != "" && part.PkgName != pkg {
+				return nil, fmt.Errorf("found packages %s and %s", part.PkgName, pkg)
+			}
+			part.PkgName = pkg
+		}
+		part.Files = append(part.Files, f)
+	}
+
+	for _, file := range files {
+		if !slices.ContainsFunc(part.Files, func(f *ast.File) bool { return f.Filename == file }) {
+			return nil, fmt.Errorf("file %s not loaded", file)
+		}
+	}
+
+	return &part, nil
+}
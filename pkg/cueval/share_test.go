@@ -0,0 +1,139 @@
+package cueval_test
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"cuelang.org/go/encoding/yaml"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/jace-ys/konduit/pkg/cueval"
+)
+
+func TestEvaluator_Share(t *testing.T) {
+	t.Parallel()
+
+	root := writeModule(t, map[string]string{
+		"lib/lib.cue":               "package lib\n\n#Name: \"web\"\n",
+		"release/values.cue":        "package values\n\nimport \"example.com/app/lib\"\n\nname: lib.#Name\nenv: #Konduit.env\n",
+		"release/production.cue":    "package values\n\nreplicas: 3\n",
+		"release/patches.cue":       "package patches\n\nimport \"example.com/app/lib\"\n\nnamePrefix: lib.#Name + \"-\" + #Konduit.env + \"-\"\n",
+		"release/conflict.cue":      "package values\n\nreplicas: 1\n",
+		"release/invalid.cue":       "package values\n\nname: {\n",
+		"release/other/package.cue": "package other\n\nfoo: 1\n",
+	})
+	file := func(name string) string {
+		return filepath.Join(root, filepath.FromSlash(name))
+	}
+
+	values := []string{file("release/values.cue"), file("release/production.cue")}
+	patches := []string{file("release/patches.cue")}
+
+	tests := []struct {
+		name      string
+		fileSets  [][]string
+		want      []string
+		wantErr   []string
+		wantLoads int
+	}{
+		{
+			name:      "loads the sets together",
+			fileSets:  [][]string{values, patches},
+			want:      []string{"name: web\nenv: production\nreplicas: 3\n", "namePrefix: web-production-\n"},
+			wantLoads: 1,
+		},
+		{
+			name:      "shares files between sets",
+			fileSets:  [][]string{values, {file("release/values.cue")}},
+			want:      []string{"name: web\nenv: production\nreplicas: 3\n", "name: web\nenv: production\n"},
+			wantLoads: 1,
+		},
+		{
+			name:      "reports the errors of every set",
+			fileSets:  [][]string{{file("release/production.cue"), file("release/conflict.cue")}, {file("release/conflict.cue"), file("release/production.cue")}},
+			wantErr:   []string{"replicas: conflicting values", "replicas: conflicting values"},
+			wantLoads: 1,
+		},
+		{
+			name:      "loads sets separately when they can't be loaded together",
+			fileSets:  [][]string{{file("release/invalid.cue")}, patches},
+			want:      []string{"", "namePrefix: web-production-\n"},
+			wantErr:   []string{"load instance", ""},
+			wantLoads: 3,
+		},
+		{
+			name:      "loads sets of several packages separately",
+			fileSets:  [][]string{{file("release/values.cue"), file("release/other/package.cue")}, patches},
+			want:      []string{"", "namePrefix: web-production-\n"},
+			wantErr:   []string{`found packages "values" (values.cue) and "other" (package.cue)`, ""},
+			wantLoads: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			eval := cueval.NewEvaluator(
+				cueval.WithLoadDir(root),
+				cueval.WithScopes("env: production"),
+				cueval.WithTracerProvider(provider),
+			).Share(tt.fileSets...)
+
+			var wg sync.WaitGroup
+			got := make([]string, len(tt.fileSets))
+			errs := make([]error, len(tt.fileSets))
+			for idx, files := range tt.fileSets {
+				wg.Go(func() {
+					value, err := eval.Eval(t.Context(), files)
+					if err != nil {
+						errs[idx] = err
+						return
+					}
+
+					data, err := yaml.Encode(value)
+					require.NoError(t, err)
+					got[idx] = string(data)
+				})
+			}
+			wg.Wait()
+
+			for idx := range tt.fileSets {
+				if idx < len(tt.wantErr) && tt.wantErr[idx] != "" {
+					assert.ErrorContains(t, errs[idx], tt.wantErr[idx])
+					continue
+				}
+				require.NoError(t, errs[idx])
+				assert.Equal(t, tt.want[idx], got[idx])
+			}
+
+			loads := 0
+			for _, span := range recorder.Ended() {
+				if span.Name() == "cue load" {
+					loads++
+				}
+			}
+			assert.Equal(t, tt.wantLoads, loads)
+		})
+	}
+}
+
+func TestEvaluator_ShareOtherFiles(t *testing.T) {
+	t.Parallel()
+
+	eval := cueval.NewEvaluator().Share([]string{"testdata/scope.cue"})
+
+	value, err := eval.Eval(t.Context(), []string{"testdata/simple.cue"})
+	require.NoError(t, err)
+
+	data, err := yaml.Encode(value)
+	require.NoError(t, err)
+	assert.Equal(t, "foo: hello\nbar: 42\n", string(data))
+}
@@ -0,0 +1,270 @@
+package konduit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/jace-ys/konduit/internal/exec"
+)
+
+// Placeholders for paths that change between runs, which are substituted in
+// recorded commands so that cassettes are reproducible.
+const (
+	WorkDirPlaceholder = "$KONDUIT_WORK_DIR"
+	BinaryPlaceholder  = "$KONDUIT_BINARY"
+)
+
+// Cassette is a recording of the commands run by Konduit.
+type Cassette struct {
+	Interactions []Interaction `yaml:"interactions"`
+}
+
+type Interaction struct {
+	Command  string   `yaml:"command"`
+	Args     []string `yaml:"args,omitempty"`
+	Stdin    string   `yaml:"stdin,omitempty"`
+	Stdout   string   `yaml:"stdout,omitempty"`
+	ExitCode int      `yaml:"exitCode,omitempty"`
+	Error    string   `yaml:"error,omitempty"`
+}
+
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read cassette: %w", err)
+	}
+
+	cassette := new(Cassette)
+	if err := yaml.Unmarshal(data, cassette); err != nil {
+		return nil, fmt.Errorf("decode cassette: %w", err)
+	}
+
+	return cassette, nil
+}
+
+func (c *Cassette) Save(path string) error {
+	data, err := yaml.MarshalWithOptions(c, yaml.UseLiteralStyleIfMultiline(true))
+	if err != nil {
+		return fmt.Errorf("encode cassette: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("write cassette: %w", err)
+	}
+
+	return nil
+}
+
+// RecordingRunner runs commands with another Runner, and records each command
+// along with its stdin, stdout and exit code to a cassette file.
+type RecordingRunner struct {
+	runner Runner
+	path   string
+	paths  paths
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+func NewRecordingRunner(runner Runner, path string) *RecordingRunner {
+	return &RecordingRunner{runner: runner, path: path}
+}
+
+func (r *RecordingRunner) Run(ctx context.Context, command string, args []string, opts ...RunOption) error {
+	stdin, stdout := exec.Streams(opts...)
+
+	var recordedStdin, recordedStdout bytes.Buffer
+	if stdin != nil {
+		opts = append(opts, exec.WithStdin(io.TeeReader(stdin, &recordedStdin)))
+	}
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	opts = append(opts, exec.WithStdout(io.MultiWriter(stdout, &recordedStdout)))
+
+	runErr := r.runner.Run(ctx, command, args, opts...)
+
+	interaction := Interaction{
+		Command: command,
+		Args:    r.paths.normalizeArgs(args),
+		Stdin:   r.paths.normalize(recordedStdin.String()),
+		Stdout:  r.paths.normalize(recordedStdout.String()),
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(runErr, &exitErr):
+		interaction.ExitCode = exitErr.ExitCode()
+	case runErr != nil:
+		interaction.Error = runErr.Error()
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	if err := r.cassette.Save(r.path); err != nil {
+		return errors.Join(runErr, err)
+	}
+
+	return runErr
+}
+
+// ReplayRunner serves commands from a cassette instead of running them. Each
+// command must match the next recorded interaction, so changes to the
+// arguments Konduit generates are reported as errors.
+type ReplayRunner struct {
+	mu           sync.Mutex
+	interactions []Interaction
+	paths        paths
+}
+
+func NewReplayRunner(cassette *Cassette) *ReplayRunner {
+	return &ReplayRunner{interactions: cassette.Interactions}
+}
+
+func (r *ReplayRunner) Run(ctx context.Context, command string, args []string, opts ...RunOption) error {
+	stdin, stdout := exec.Streams(opts...)
+
+	var input []byte
+	if stdin != nil {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return fmt.Errorf("read stdin: %w", err)
+		}
+		input = data
+	}
+
+	r.mu.Lock()
+	if len(r.interactions) == 0 {
+		r.mu.Unlock()
+		return fmt.Errorf("unexpected command %q: no recorded interactions left", command)
+	}
+	interaction := r.interactions[0]
+	r.interactions = r.interactions[1:]
+	r.mu.Unlock()
+
+	got := Interaction{Command: command, Args: r.paths.normalizeArgs(args), Stdin: r.paths.normalize(string(input))}
+	if err := interaction.match(got); err != nil {
+		return err
+	}
+
+	if stdout == nil {
+		stdout = os.Stdout
+	}
+	if _, err := io.WriteString(stdout, interaction.Stdout); err != nil {
+		return fmt.Errorf("write stdout: %w", err)
+	}
+
+	switch {
+	case interaction.ExitCode != 0:
+		return &exec.ExitError{Command: command, Code: interaction.ExitCode}
+	case interaction.Error != "":
+		return errors.New(interaction.Error)
+	}
+
+	return nil
+}
+
+// Remaining returns the number of recorded interactions that have not been
+// replayed.
+func (r *ReplayRunner) Remaining() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.interactions)
+}
+
+func (i Interaction) match(got Interaction) error {
+	if got.Command != i.Command || !slices.Equal(got.Args, i.Args) {
+		return fmt.Errorf("unexpected command:\n  recorded: %s\n  got:      %s", i.commandLine(), got.commandLine())
+	}
+
+	if got.Stdin != i.Stdin {
+		return fmt.Errorf("unexpected stdin for %s", got.commandLine())
+	}
+
+	return nil
+}
+
+func (i Interaction) commandLine() string {
+	return strings.Join(append([]string{i.Command}, i.Args...), " ")
+}
+
+// workDirRecorder is implemented by the runners that record and replay
+// cassettes, so that a work dir set with WithWorkDir is normalized too.
+type workDirRecorder interface {
+	addWorkDir(dir string)
+}
+
+func (r *RecordingRunner) addWorkDir(dir string) { r.paths.addWorkDir(dir) }
+func (r *ReplayRunner) addWorkDir(dir string)    { r.paths.addWorkDir(dir) }
+
+var (
+	workDirPattern = regexp.MustCompile(regexp.QuoteMeta(filepath.Join(os.TempDir(), "konduit-")) + `[^/\\\s]+`)
+	konduitBinary  = sync.OnceValue(resolveKonduitBinary)
+)
+
+// paths substitutes placeholders for the paths in recorded commands that
+// change between runs: temporary work dirs, work dirs that were set, and the
+// Konduit binary.
+type paths struct {
+	mu       sync.Mutex
+	workDirs []*regexp.Regexp
+}
+
+func (p *paths) addWorkDir(dir string) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+
+	// The work dir must not be followed by more of a file name, so that a
+	// sibling such as dir-2 isn't replaced.
+	pattern := regexp.QuoteMeta(filepath.Clean(dir)) + `([^\w.-]|$)`
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !slices.ContainsFunc(p.workDirs, func(re *regexp.Regexp) bool { return re.String() == pattern }) {
+		p.workDirs = append(p.workDirs, regexp.MustCompile(pattern))
+	}
+}
+
+func (p *paths) normalize(s string) string {
+	p.mu.Lock()
+	workDirs := p.workDirs
+	p.mu.Unlock()
+
+	placeholder := strings.ReplaceAll(WorkDirPlaceholder, "$", "$$") + "${1}"
+	for _, workDir := range workDirs {
+		s = workDir.ReplaceAllString(s, placeholder)
+	}
+
+	s = workDirPattern.ReplaceAllLiteralString(s, WorkDirPlaceholder)
+	if binary := konduitBinary(); filepath.IsAbs(binary) {
+		s = strings.ReplaceAll(s, binary, BinaryPlaceholder)
+	}
+	return s
+}
+
+func (p *paths) normalizeArgs(args []string) []string {
+	if len(args) == 0 {
+		return nil
+	}
+
+	normalized := make([]string, len(args))
+	for idx, arg := range args {
+		normalized[idx] = p.normalize(arg)
+	}
+	return normalized
+}
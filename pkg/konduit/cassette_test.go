@@ -0,0 +1,141 @@
+package konduit_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/pkg/konduit"
+	"github.com/jace-ys/konduit/pkg/konduit/mocks"
+)
+
+func TestRecordingRunner_Run(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "cassette.yaml")
+	workDir := filepath.Join(os.TempDir(), "konduit-12345")
+
+	runner := mocks.NewMockRunner(t)
+	runner.EXPECT().
+		Run(mock.Anything, "helm", mock.Anything, mock.Anything).
+		RunAndReturn(func(ctx context.Context, command string, args []string, opts ...exec.RunOption) error {
+			stdin, stdout := exec.Streams(opts...)
+			_, err := io.Copy(stdout, stdin)
+			return err
+		})
+
+	var stdout bytes.Buffer
+	recorder := konduit.NewRecordingRunner(runner, path)
+
+	err := recorder.Run(t.Context(), "helm", []string{"template", "--values", filepath.Join(workDir, "evaluated.yaml")},
+		exec.WithStdin(strings.NewReader("kind: ConfigMap\n")),
+		exec.WithStdout(&stdout),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, "kind: ConfigMap\n", stdout.String())
+
+	cassette, err := konduit.LoadCassette(path)
+	require.NoError(t, err)
+	assert.Equal(t, []konduit.Interaction{{
+		Command: "helm",
+		Args:    []string{"template", "--values", konduit.WorkDirPlaceholder + "/evaluated.yaml"},
+		Stdin:   "kind: ConfigMap\n",
+		Stdout:  "kind: ConfigMap\n",
+	}}, cassette.Interactions)
+}
+
+func TestReplayRunner_Run(t *testing.T) {
+	t.Parallel()
+
+	cassette := &konduit.Cassette{
+		Interactions: []konduit.Interaction{
+			{
+				Command: "helm",
+				Args:    []string{"template", "--values", konduit.WorkDirPlaceholder + "/evaluated.yaml"},
+				Stdout:  "kind: ConfigMap\n",
+			},
+			{
+				Command:  "helm",
+				Args:     []string{"diff", "upgrade"},
+				ExitCode: 2,
+			},
+		},
+	}
+
+	t.Run("serves recorded interactions in order", func(t *testing.T) {
+		t.Parallel()
+
+		replay := konduit.NewReplayRunner(cassette)
+		workDir := filepath.Join(os.TempDir(), "konduit-67890")
+
+		var stdout bytes.Buffer
+		err := replay.Run(t.Context(), "helm", []string{"template", "--values", filepath.Join(workDir, "evaluated.yaml")}, exec.WithStdout(&stdout))
+		require.NoError(t, err)
+		assert.Equal(t, "kind: ConfigMap\n", stdout.String())
+
+		err = replay.Run(t.Context(), "helm", []string{"diff", "upgrade"}, exec.WithStdout(io.Discard))
+		var exitErr *exec.ExitError
+		require.ErrorAs(t, err, &exitErr)
+		assert.Equal(t, 2, exitErr.ExitCode())
+
+		assert.Zero(t, replay.Remaining())
+	})
+
+	t.Run("returns error when args differ", func(t *testing.T) {
+		t.Parallel()
+
+		replay := konduit.NewReplayRunner(cassette)
+
+		err := replay.Run(t.Context(), "helm", []string{"template", "--debug"}, exec.WithStdout(io.Discard))
+		assert.ErrorContains(t, err, "unexpected command")
+	})
+
+	t.Run("returns error when no interactions are left", func(t *testing.T) {
+		t.Parallel()
+
+		replay := konduit.NewReplayRunner(&konduit.Cassette{})
+
+		err := replay.Run(t.Context(), "helm", []string{"version"})
+		assert.ErrorContains(t, err, "no recorded interactions left")
+	})
+}
+
+func TestRecordingRunner_WorkDir(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	workDir := filepath.Join(dir, "work")
+	path := filepath.Join(dir, "cassette.yaml")
+
+	runner := mocks.NewMockRunner(t)
+	runner.EXPECT().Run(mock.Anything, "helm", mock.Anything, mock.Anything).Return(nil)
+
+	recorder := konduit.NewRecordingRunner(runner, path)
+	_, err := konduit.New([]string{"template"}, nil, konduit.WithRunner(recorder), konduit.WithWorkDir(workDir))
+	require.NoError(t, err)
+
+	args := []string{
+		"--values", filepath.Join(workDir, "evaluated.yaml"),
+		"--post-renderer-args", workDir,
+		"--values", workDir + "-2/values.yaml",
+	}
+	require.NoError(t, recorder.Run(t.Context(), "helm", args, exec.WithStdout(io.Discard)))
+
+	cassette, err := konduit.LoadCassette(path)
+	require.NoError(t, err)
+	require.Len(t, cassette.Interactions, 1)
+	assert.Equal(t, []string{
+		"--values", konduit.WorkDirPlaceholder + "/evaluated.yaml",
+		"--post-renderer-args", konduit.WorkDirPlaceholder,
+		"--values", workDir + "-2/values.yaml",
+	}, cassette.Interactions[0].Args)
+}
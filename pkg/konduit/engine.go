@@ -0,0 +1,43 @@
+package konduit
+
+import (
+	"context"
+	"io"
+	"os"
+
+	"github.com/jace-ys/konduit/internal/exec"
+)
+
+type Engine interface {
+	Name() string
+	DefaultCommand() string
+	Validate(i *Instance) error
+	Prepare(ctx context.Context, i *Instance) error
+	ConstructArgs(i *Instance) []string
+	Run(ctx context.Context, i *Instance, inv *Invocation) error
+}
+
+func (i *Instance) Engine() Engine {
+	if i.engine == nil {
+		return NewHelmEngine()
+	}
+	return i.engine
+}
+
+func (i *Instance) Prepare(ctx context.Context) error {
+	return i.Engine().Prepare(ctx, i)
+}
+
+func (i *Instance) runOptions() []exec.RunOption {
+	if i.stdout == nil {
+		return nil
+	}
+	return []exec.RunOption{exec.WithStdout(i.stdout)}
+}
+
+func (i *Instance) output() io.Writer {
+	if i.stdout == nil {
+		return os.Stdout
+	}
+	return i.stdout
+}
@@ -1,8 +1,14 @@
 package konduit
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"path/filepath"
 
+	"cuelang.org/go/cue/parser"
 	"cuelang.org/go/encoding/yaml"
 
 	"github.com/jace-ys/konduit/pkg/cueval"
@@ -10,13 +16,19 @@ import (
 
 //mockery:generate: true
 type Evaluator interface {
-	Evaluate(files []string) (result []byte, err error)
+	Evaluate(ctx context.Context, files []string) (result *Result, err error)
 	SupportedFileExt() string
 }
 
-type Evaluation struct {
-	Files      []string `json:"files,omitempty"`
-	ResultYAML string   `json:"result,omitempty"`
+func PartitionFiles(evaluator Evaluator, files []string) (evaluated []string, static []string) {
+	for _, file := range files {
+		if filepath.Ext(file) == evaluator.SupportedFileExt() {
+			evaluated = append(evaluated, file)
+		} else {
+			static = append(static, file)
+		}
+	}
+	return evaluated, static
 }
 
 type NoopEvaluator struct{}
@@ -25,36 +37,204 @@ func NewNoopEvaluator() *NoopEvaluator {
 	return &NoopEvaluator{}
 }
 
-func (e *NoopEvaluator) Evaluate(files []string) ([]byte, error) {
-	return []byte{}, nil
+func (e *NoopEvaluator) Evaluate(ctx context.Context, files []string) (*Result, error) {
+	return &Result{Data: map[string]any{}, Encoding: EncodingYAML}, nil
 }
 
 func (e *NoopEvaluator) SupportedFileExt() string {
 	return ""
 }
 
+// CUEEvaluator is safe for concurrent use. Evaluations share a single module
+// registry, and each builds its values in a fresh CUE context. Each loads the
+// module on its own, unless shared with Share.
 type CUEEvaluator struct {
-	opts []cueval.Option
+	eval *cueval.Evaluator
 }
 
 func NewCUEEvaluator(opts ...cueval.Option) *CUEEvaluator {
-	return &CUEEvaluator{opts: opts}
+	return &CUEEvaluator{eval: cueval.NewEvaluator(opts...)}
 }
 
-func (e *CUEEvaluator) Evaluate(files []string) ([]byte, error) {
-	value, err := cueval.Eval(files, e.opts...)
+func (e *CUEEvaluator) Evaluate(ctx context.Context, files []string) (*Result, error) {
+	value, err := e.eval.Eval(ctx, files)
 	if err != nil {
 		return nil, fmt.Errorf("evaluate CUE: %w", err)
 	}
 
-	result, err := yaml.Encode(value)
+	data := make(map[string]any)
+	if err := value.Decode(&data); err != nil {
+		return nil, fmt.Errorf("decode CUE value: %w", err)
+	}
+
+	raw, err := yaml.Encode(value)
 	if err != nil {
 		return nil, fmt.Errorf("encode CUE value: %w", err)
 	}
 
-	return result, nil
+	return &Result{
+		Data:     data,
+		Encoding: EncodingYAML,
+		Raw:      raw,
+		Files:    cueFileMetadata(files),
+	}, nil
+}
+
+func cueFileMetadata(files []string) []FileMetadata {
+	metadata := make([]FileMetadata, 0, len(files))
+	for _, file := range files {
+		md := FileMetadata{Path: file}
+		if f, err := parser.ParseFile(file, nil, parser.PackageClauseOnly); err == nil {
+			md.Package = f.PackageName()
+		}
+		metadata = append(metadata, md)
+	}
+	return metadata
 }
 
 func (e *CUEEvaluator) SupportedFileExt() string {
 	return ".cue"
 }
+
+// Share returns a CUEEvaluator that loads the module and the packages imported
+// by fileSets once, the first time one of them is evaluated.
+func (e *CUEEvaluator) Share(fileSets [][]string) Evaluator {
+	return &CUEEvaluator{eval: e.eval.Share(fileSets...)}
+}
+
+func (e *CUEEvaluator) Digest(files []string) (string, error) {
+	return e.eval.Digest(files)
+}
+
+func (e *CUEEvaluator) Dependencies(files []string) ([]string, error) {
+	return e.eval.Dependencies(files)
+}
+
+// DependencyEvaluator is an Evaluator that can report every file that
+// contributes to the result of evaluating a set of files, such as imports.
+type DependencyEvaluator interface {
+	Evaluator
+	Dependencies(files []string) (deps []string, err error)
+}
+
+// SharingEvaluator is an Evaluator that can share the work that evaluating
+// several sets of files concurrently has in common, such as loading a module.
+type SharingEvaluator interface {
+	Evaluator
+	Share(fileSets [][]string) Evaluator
+}
+
+type Cache interface {
+	Get(key string) (data []byte, ok bool)
+	Put(key string, data []byte) error
+}
+
+// DigestEvaluator is an Evaluator that can compute a digest of everything that
+// contributes to the result of evaluating a set of files.
+type DigestEvaluator interface {
+	Evaluator
+	Digest(files []string) (digest string, err error)
+}
+
+// CachedEvaluator skips evaluation when a result for the same inputs has
+// already been cached. The salt is mixed into every cache key, and should
+// change whenever the evaluation logic might, such as across Konduit versions.
+type CachedEvaluator struct {
+	evaluator DigestEvaluator
+	cache     Cache
+	salt      string
+}
+
+func NewCachedEvaluator(evaluator DigestEvaluator, cache Cache, salt string) *CachedEvaluator {
+	return &CachedEvaluator{
+		evaluator: evaluator,
+		cache:     cache,
+		salt:      salt,
+	}
+}
+
+func (e *CachedEvaluator) Evaluate(ctx context.Context, files []string) (*Result, error) {
+	digest, err := e.evaluator.Digest(files)
+	if err != nil {
+		return nil, fmt.Errorf("compute digest: %w", err)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n%s\n", e.salt, e.evaluator.SupportedFileExt(), digest)
+	key := hex.EncodeToString(h.Sum(nil))
+
+	if data, ok := e.cache.Get(key); ok {
+		if result, err := decodeCacheEntry(data); err == nil {
+			return result, nil
+		}
+	}
+
+	result, err := e.evaluator.Evaluate(ctx, files)
+	if err != nil {
+		return nil, err
+	}
+
+	// Caching is best-effort, so an unwritable cache doesn't fail evaluation.
+	if data, err := encodeCacheEntry(result); err == nil {
+		_ = e.cache.Put(key, data)
+	}
+
+	return result, nil
+}
+
+// Share shares the underlying evaluator between fileSets, if it can be shared
+// and still compute digests.
+func (e *CachedEvaluator) Share(fileSets [][]string) Evaluator {
+	sharing, ok := e.evaluator.(SharingEvaluator)
+	if !ok {
+		return e
+	}
+
+	evaluator, ok := sharing.Share(fileSets).(DigestEvaluator)
+	if !ok {
+		return e
+	}
+
+	return NewCachedEvaluator(evaluator, e.cache, e.salt)
+}
+
+func (e *CachedEvaluator) SupportedFileExt() string {
+	return e.evaluator.SupportedFileExt()
+}
+
+// Dependencies returns the dependencies reported by the underlying evaluator,
+// or just the given files if it can't report them.
+func (e *CachedEvaluator) Dependencies(files []string) ([]string, error) {
+	if deps, ok := e.evaluator.(DependencyEvaluator); ok {
+		return deps.Dependencies(files)
+	}
+	return files, nil
+}
+
+// cacheEntry stores a result in its original encoding, which is decoded again
+// on a cache hit.
+type cacheEntry struct {
+	Encoding Encoding       `json:"encoding"`
+	Raw      []byte         `json:"raw"`
+	Files    []FileMetadata `json:"files,omitempty"`
+}
+
+func encodeCacheEntry(result *Result) ([]byte, error) {
+	if result.Encoding != EncodingYAML {
+		return nil, fmt.Errorf("unsupported encoding: %s", result.Encoding)
+	}
+	return json.Marshal(cacheEntry{Encoding: result.Encoding, Raw: result.Raw, Files: result.Files})
+}
+
+func decodeCacheEntry(data []byte) (*Result, error) {
+	var entry cacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		return nil, err
+	}
+
+	if entry.Encoding != EncodingYAML {
+		return nil, fmt.Errorf("unsupported encoding: %s", entry.Encoding)
+	}
+
+	return NewYAMLResult(entry.Raw, entry.Files)
+}
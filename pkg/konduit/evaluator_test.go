@@ -0,0 +1,137 @@
+package konduit_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+type countingEvaluator struct {
+	digest      string
+	evaluations int
+}
+
+func (e *countingEvaluator) Evaluate(ctx context.Context, files []string) (*konduit.Result, error) {
+	e.evaluations++
+	return konduit.NewYAMLResult([]byte("greeting: hi\n"), []konduit.FileMetadata{{Path: "values.cue"}})
+}
+
+func (e *countingEvaluator) SupportedFileExt() string {
+	return ".cue"
+}
+
+func (e *countingEvaluator) Digest(files []string) (string, error) {
+	if e.digest == "" {
+		return "", errors.New("digest failed")
+	}
+	return e.digest, nil
+}
+
+type mapCache map[string][]byte
+
+func (c mapCache) Get(key string) ([]byte, bool) {
+	data, ok := c[key]
+	return data, ok
+}
+
+func (c mapCache) Put(key string, data []byte) error {
+	c[key] = data
+	return nil
+}
+
+func TestCachedEvaluator_Evaluate(t *testing.T) {
+	t.Parallel()
+
+	t.Run("skips evaluation for cached inputs", func(t *testing.T) {
+		t.Parallel()
+
+		inner := &countingEvaluator{digest: "abc"}
+		eval := konduit.NewCachedEvaluator(inner, mapCache{}, "v1")
+
+		for range 2 {
+			result, err := eval.Evaluate(t.Context(), []string{"values.cue"})
+			require.NoError(t, err)
+			assert.Equal(t, "greeting: hi\n", string(result.Raw))
+			assert.Equal(t, map[string]any{"greeting": "hi"}, result.Data)
+			assert.Equal(t, []konduit.FileMetadata{{Path: "values.cue"}}, result.Files)
+		}
+
+		assert.Equal(t, 1, inner.evaluations)
+	})
+
+	t.Run("evaluates when digest or salt changes", func(t *testing.T) {
+		t.Parallel()
+
+		cache := mapCache{}
+		inner := &countingEvaluator{digest: "abc"}
+
+		_, err := konduit.NewCachedEvaluator(inner, cache, "v1").Evaluate(t.Context(), []string{"values.cue"})
+		require.NoError(t, err)
+
+		_, err = konduit.NewCachedEvaluator(inner, cache, "v2").Evaluate(t.Context(), []string{"values.cue"})
+		require.NoError(t, err)
+
+		inner.digest = "def"
+		_, err = konduit.NewCachedEvaluator(inner, cache, "v2").Evaluate(t.Context(), []string{"values.cue"})
+		require.NoError(t, err)
+
+		assert.Equal(t, 3, inner.evaluations)
+		assert.Len(t, cache, 3)
+	})
+
+	t.Run("returns error when digest fails", func(t *testing.T) {
+		t.Parallel()
+
+		eval := konduit.NewCachedEvaluator(&countingEvaluator{}, mapCache{}, "v1")
+
+		_, err := eval.Evaluate(t.Context(), []string{"values.cue"})
+		assert.ErrorContains(t, err, "compute digest: digest failed")
+	})
+}
+
+// sharingEvaluator records the file sets that it's shared between.
+type sharingEvaluator struct {
+	countingEvaluator
+	fileSets [][]string
+}
+
+func (e *sharingEvaluator) Share(fileSets [][]string) konduit.Evaluator {
+	e.fileSets = fileSets
+	return &e.countingEvaluator
+}
+
+func TestCachedEvaluator_Share(t *testing.T) {
+	t.Parallel()
+
+	fileSets := [][]string{{"values.cue"}, {"patches.cue"}}
+
+	t.Run("shares the underlying evaluator and the cache", func(t *testing.T) {
+		t.Parallel()
+
+		inner := &sharingEvaluator{countingEvaluator: countingEvaluator{digest: "abc"}}
+		eval := konduit.NewCachedEvaluator(inner, mapCache{}, "v1")
+
+		shared := eval.Share(fileSets)
+		assert.Equal(t, fileSets, inner.fileSets)
+
+		_, err := shared.Evaluate(t.Context(), []string{"values.cue"})
+		require.NoError(t, err)
+
+		_, err = eval.Evaluate(t.Context(), []string{"values.cue"})
+		require.NoError(t, err)
+
+		assert.Equal(t, 1, inner.evaluations)
+	})
+
+	t.Run("returns itself when the underlying evaluator can't be shared", func(t *testing.T) {
+		t.Parallel()
+
+		eval := konduit.NewCachedEvaluator(&countingEvaluator{digest: "abc"}, mapCache{}, "v1")
+		assert.Same(t, eval, eval.Share(fileSets))
+	})
+}
@@ -0,0 +1,96 @@
+package konduit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/internal/tracing"
+)
+
+const (
+	HelmVersion3 = 3
+	HelmVersion4 = 4
+)
+
+type HelmEngine struct{}
+
+func NewHelmEngine() *HelmEngine {
+	return &HelmEngine{}
+}
+
+func (e *HelmEngine) Name() string {
+	return "helm"
+}
+
+func (e *HelmEngine) DefaultCommand() string {
+	return DefaultHelmCommand
+}
+
+func (e *HelmEngine) Validate(i *Instance) error {
+	if i.HelmVersion != 0 && i.HelmVersion < HelmVersion3 {
+		return fmt.Errorf("unsupported helm version %d", i.HelmVersion)
+	}
+	return nil
+}
+
+func (e *HelmEngine) Prepare(ctx context.Context, i *Instance) error {
+	if err := i.ResolveHelmVersion(ctx); err != nil {
+		return fmt.Errorf("resolve helm version: %w", err)
+	}
+	return nil
+}
+
+func (e *HelmEngine) ConstructArgs(i *Instance) []string {
+	return i.constructHelmArgs()
+}
+
+func (e *HelmEngine) Run(ctx context.Context, i *Instance, inv *Invocation) (err error) {
+	ctx, span := i.startSpan(ctx, "helm run", attribute.String("konduit.command", inv.Command))
+	defer func() { tracing.End(span, err) }()
+
+	return i.runner.Run(ctx, inv.Command, inv.Args, i.runOptions()...)
+}
+
+func (i *Instance) ResolveHelmVersion(ctx context.Context) error {
+	if i.HelmVersion != 0 || !i.usesKonduitPostRenderer() {
+		return nil
+	}
+
+	var stdout bytes.Buffer
+	args := []string{"version", "--template", "{{ .Version }}"}
+
+	if err := i.runner.Run(ctx, i.HelmCommand, args, exec.WithStdout(&stdout)); err != nil {
+		return fmt.Errorf("run helm version: %w", err)
+	}
+
+	version, err := parseHelmVersion(stdout.String())
+	if err != nil {
+		return err
+	}
+	i.HelmVersion = version
+
+	i.log().Debug("resolved helm version", "command", i.HelmCommand, "version", version)
+	return nil
+}
+
+func parseHelmVersion(version string) (int, error) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+	major, _, _ := strings.Cut(version, ".")
+
+	n, err := strconv.Atoi(major)
+	if err != nil || n < HelmVersion3 {
+		return 0, fmt.Errorf("unsupported helm version %q", version)
+	}
+
+	return n, nil
+}
+
+func (i *Instance) hasPatches() bool {
+	return len(i.Patches) > 0 || len(i.PatchesToEvaluate) > 0
+}
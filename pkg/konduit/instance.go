@@ -3,8 +3,16 @@ package konduit
 import (
 	"context"
 	"errors"
+	"io"
+	"log/slog"
 	"path/filepath"
+	"slices"
 	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/jace-ys/konduit/internal/exec"
 )
@@ -13,16 +21,26 @@ const DefaultHelmCommand = "helm"
 
 //mockery:generate: true
 type Runner interface {
-	Run(ctx context.Context, command string, args []string, opts ...exec.RunOption) error
+	Run(ctx context.Context, command string, args []string, opts ...RunOption) error
 }
 
 type Instance struct {
+	// HelmCommand and HelmArgs are the command and arguments run by the
+	// engine, whichever engine it is. They are named after Helm because it
+	// was the only engine when they were added.
 	HelmCommand string
 	HelmArgs    []string
+	HelmVersion int
 
 	PostRenderer     string
 	PostRendererArgs []string
 
+	// PostRenderersBefore run on the manifests rendered by Helm or Timoni,
+	// before patches are applied, and PostRenderersAfter run after them and
+	// after PostRenderer. They run in order, streaming from one to the next.
+	PostRenderersBefore []PostRenderer
+	PostRenderersAfter  []PostRenderer
+
 	Values           []string
 	ValuesToEvaluate []string
 
@@ -30,47 +48,60 @@ type Instance struct {
 	PatchesToEvaluate []string
 	patchesOpt        []string
 
-	dir    string
-	strict bool
-
-	evaluator Evaluator
-	runner    Runner
+	dir     string
+	keepDir bool
+	// sharedDir is set once a work dir that was set is in use.
+	sharedDir bool
+	strict    bool
+	stdout    io.Writer
+
+	engine      Engine
+	evaluator   Evaluator
+	evalTimeout time.Duration
+	runner      Runner
+	logger      *slog.Logger
+	tracer      trace.TracerProvider
 }
 
 //nolint:cyclop
 func New(args []string, values []string, opts ...Option) (*Instance, error) {
 	if len(args) == 0 {
-		return nil, errors.New("no arguments provided to Helm")
+		return nil, errors.New("no arguments provided to engine")
 	}
 
 	instance := &Instance{
-		HelmCommand: DefaultHelmCommand,
-		evaluator:   NewNoopEvaluator(),
-		runner:      exec.NewOSRunner(),
+		engine:    NewHelmEngine(),
+		evaluator: NewNoopEvaluator(),
 	}
 
 	for _, opt := range opts {
 		opt.Apply(instance)
 	}
 
-	for _, value := range values {
-		if filepath.Ext(value) == instance.evaluator.SupportedFileExt() {
-			instance.ValuesToEvaluate = append(instance.ValuesToEvaluate, value)
-		} else {
-			instance.Values = append(instance.Values, value)
-		}
+	if instance.runner == nil {
+		instance.runner = exec.NewOSRunner(exec.WithLogger(instance.log()))
 	}
 
-	for _, patch := range instance.patchesOpt {
-		if filepath.Ext(patch) == instance.evaluator.SupportedFileExt() {
-			instance.PatchesToEvaluate = append(instance.PatchesToEvaluate, patch)
-		} else {
-			instance.Patches = append(instance.Patches, patch)
-		}
+	if runner, ok := instance.runner.(workDirRecorder); ok && instance.dir != "" {
+		runner.addWorkDir(instance.dir)
+	}
+
+	if instance.HelmCommand == "" {
+		instance.HelmCommand = instance.engine.DefaultCommand()
 	}
 
+	instance.ValuesToEvaluate, instance.Values = PartitionFiles(instance.evaluator, values)
+	instance.PatchesToEvaluate, instance.Patches = PartitionFiles(instance.evaluator, instance.patchesOpt)
+
+	instance.log().Debug("classified values files", "evaluated", instance.ValuesToEvaluate, "static", instance.Values)
+	instance.log().Debug("classified patches files", "evaluated", instance.PatchesToEvaluate, "static", instance.Patches)
+
 	parseHelmArgs(instance, args)
 
+	if err := instance.engine.Validate(instance); err != nil {
+		return nil, err
+	}
+
 	if instance.strict {
 		if len(instance.ValuesToEvaluate) > 0 && len(instance.Values) > 0 {
 			return nil, errors.New("strict mode enabled; can't use evaluated and static values at the same time")
@@ -83,6 +114,28 @@ func New(args []string, values []string, opts ...Option) (*Instance, error) {
 	return instance, nil
 }
 
+var discardLogger = slog.New(slog.DiscardHandler)
+
+func (i *Instance) log() *slog.Logger {
+	if i.logger == nil {
+		return discardLogger
+	}
+	return i.logger
+}
+
+// tracerName is the instrumentation scope of the spans of an Instance.
+const tracerName = "github.com/jace-ys/konduit/pkg/konduit"
+
+// startSpan starts a span for a stage of the invocation, using the global
+// tracer provider unless one was set with WithTracerProvider.
+func (i *Instance) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	provider := i.tracer
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+	return provider.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
 type argKind int
 
 const (
@@ -146,3 +199,37 @@ func parseHelmArgs(i *Instance, args []string) {
 		}
 	}
 }
+
+// Dependencies returns the absolute paths of the files that the output of the
+// instance depends on: static values and patches files, the files to be
+// evaluated, and the files they import if the evaluator can report them. If
+// the evaluator fails to report them, the other files are still returned along
+// with the error.
+func (i *Instance) Dependencies() ([]string, error) {
+	deps := slices.Concat(i.Values, i.Patches, i.ValuesToEvaluate, i.PatchesToEvaluate)
+
+	var errs []error
+	if evaluator, ok := i.evaluator.(DependencyEvaluator); ok {
+		for _, files := range [][]string{i.ValuesToEvaluate, i.PatchesToEvaluate} {
+			if len(files) == 0 {
+				continue
+			}
+
+			evaluated, err := evaluator.Dependencies(files)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			deps = append(deps, evaluated...)
+		}
+	}
+
+	for idx, dep := range deps {
+		if abs, err := filepath.Abs(dep); err == nil {
+			deps[idx] = abs
+		}
+	}
+
+	slices.Sort(deps)
+	return slices.Compact(deps), errors.Join(errs...)
+}
@@ -1,6 +1,8 @@
 package konduit_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -211,6 +213,12 @@ func TestInstance_New_WithCUEEvaluator(t *testing.T) {
 			opts:    []konduit.Option{konduit.WithModeStrict(true)},
 			wantErr: "strict mode enabled; can't use evaluated and static values at the same time",
 		},
+		{
+			name:    "rejects unsupported helm version",
+			args:    []string{"install", "my-release", "my-chart"},
+			opts:    []konduit.Option{konduit.WithHelmVersion(2)},
+			wantErr: "unsupported helm version 2",
+		},
 		{
 			name: "strict mode rejects mixed evaluated and static patches",
 			args: []string{"install", "my-release", "my-chart"},
@@ -246,3 +254,72 @@ func TestInstance_New_WithCUEEvaluator(t *testing.T) {
 		})
 	}
 }
+
+func TestInstance_Dependencies(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"values.cue":   `replicas: 1`,
+		"patches.cue":  `patches: []`,
+		"values.yaml":  `debug: true`,
+		"patches.yaml": `patches: []`,
+	} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644))
+	}
+
+	path := func(name string) string {
+		return filepath.Join(dir, name)
+	}
+
+	tests := []struct {
+		name    string
+		values  []string
+		patches []string
+		eval    konduit.Evaluator
+		want    []string
+		wantErr string
+	}{
+		{
+			name:    "returns static files",
+			values:  []string{path("values.yaml")},
+			patches: []string{path("patches.yaml")},
+			eval:    konduit.NewNoopEvaluator(),
+			want:    []string{path("patches.yaml"), path("values.yaml")},
+		},
+		{
+			name:    "returns evaluated files",
+			values:  []string{path("values.cue"), path("values.yaml")},
+			patches: []string{path("patches.cue")},
+			eval:    konduit.NewCUEEvaluator(),
+			want:    []string{path("patches.cue"), path("values.cue"), path("values.yaml")},
+		},
+		{
+			name:    "returns files with error when evaluated files can't be loaded",
+			values:  []string{path("missing.cue"), path("values.yaml")},
+			eval:    konduit.NewCUEEvaluator(),
+			want:    []string{path("missing.cue"), path("values.yaml")},
+			wantErr: "missing.cue",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			instance, err := konduit.New([]string{"template", "my-release", "my-chart"}, tt.values,
+				konduit.WithPatches(tt.patches),
+				konduit.WithEvaluator(tt.eval),
+			)
+			require.NoError(t, err)
+
+			deps, err := instance.Dependencies()
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+			assert.Equal(t, tt.want, deps)
+		})
+	}
+}
@@ -2,18 +2,28 @@ package konduit
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
-	"os/exec"
+	osexec "os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
+	"go.opentelemetry.io/otel/attribute"
+	"sigs.k8s.io/kustomize/api/types"
+
+	"github.com/jace-ys/konduit/internal/exec"
 	"github.com/jace-ys/konduit/internal/kustomize"
+	"github.com/jace-ys/konduit/internal/tracing"
+	"github.com/jace-ys/konduit/internal/workdir"
 )
 
 const ValuesFile = "evaluated.yaml"
 
 type Invocation struct {
+	Engine           string      `json:"engine"`
 	Command          string      `json:"command"`
 	Args             []string    `json:"args"`
 	EvaluatedValues  *Evaluation `json:"evaluatedValues"`
@@ -22,33 +32,90 @@ type Invocation struct {
 	Patches          []string    `json:"patches,omitempty"`
 }
 
-func (i *Instance) Construct() (*Invocation, error) {
+func (i *Instance) Construct(ctx context.Context) (*Invocation, error) {
+	engine := i.Engine()
+
 	cmd := &Invocation{
+		Engine:           engine.Name(),
 		Command:          i.HelmCommand,
-		Args:             i.constructHelmArgs(),
+		Args:             engine.ConstructArgs(i),
 		Values:           i.Values,
 		Patches:          i.Patches,
 		EvaluatedValues:  &Evaluation{Files: i.ValuesToEvaluate},
 		EvaluatedPatches: &Evaluation{Files: i.PatchesToEvaluate},
 	}
 
-	if len(i.ValuesToEvaluate) > 0 {
-		result, err := i.evaluator.Evaluate(i.ValuesToEvaluate)
-		if err != nil {
-			return nil, fmt.Errorf("evaluate values: %w", err)
+	err := i.evaluateAll(ctx,
+		evaluationTask{name: "values", evaluation: cmd.EvaluatedValues},
+		evaluationTask{name: "patches", evaluation: cmd.EvaluatedPatches},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	i.log().Debug("constructed invocation", "engine", cmd.Engine, "command", cmd.Command, "args", exec.RedactArgs(cmd.Args))
+	return cmd, nil
+}
+
+type evaluationTask struct {
+	name       string
+	evaluation *Evaluation
+}
+
+// evaluateAll evaluates the files of each task concurrently, and reports the
+// errors from every failed task together. If the evaluator can be shared, the
+// tasks share the work they have in common, such as loading the module.
+func (i *Instance) evaluateAll(ctx context.Context, tasks ...evaluationTask) error {
+	if i.evalTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, i.evalTimeout)
+		defer cancel()
+	}
+
+	evaluator := i.evaluator
+	if sharing, ok := evaluator.(SharingEvaluator); ok {
+		var fileSets [][]string
+		for _, task := range tasks {
+			if len(task.evaluation.Files) > 0 {
+				fileSets = append(fileSets, task.evaluation.Files)
+			}
+		}
+		if len(fileSets) > 1 {
+			evaluator = sharing.Share(fileSets)
 		}
-		cmd.EvaluatedValues.ResultYAML = string(result)
 	}
 
-	if len(i.PatchesToEvaluate) > 0 {
-		result, err := i.evaluator.Evaluate(i.PatchesToEvaluate)
-		if err != nil {
-			return nil, fmt.Errorf("evaluate patches: %w", err)
+	var wg sync.WaitGroup
+	errs := make([]error, len(tasks))
+
+	for idx, task := range tasks {
+		if len(task.evaluation.Files) == 0 {
+			continue
 		}
-		cmd.EvaluatedPatches.ResultYAML = string(result)
+
+		wg.Go(func() {
+			ctx, span := i.startSpan(ctx, "evaluate "+task.name, attribute.StringSlice("konduit.files", task.evaluation.Files))
+			defer func() { tracing.End(span, errs[idx]) }()
+
+			start := time.Now()
+			result, err := evaluator.Evaluate(ctx, task.evaluation.Files)
+			if err != nil {
+				errs[idx] = fmt.Errorf("evaluate %s: %w", task.name, err)
+				return
+			}
+			task.evaluation.Result = result
+
+			i.log().Debug("evaluated "+task.name, "files", task.evaluation.Files, "duration", time.Since(start))
+		})
 	}
 
-	return cmd, nil
+	wg.Wait()
+
+	if err := errors.Join(errs...); err != nil {
+		return &EvaluationError{Err: err}
+	}
+
+	return nil
 }
 
 func (i *Instance) constructHelmArgs() []string {
@@ -62,18 +129,38 @@ func (i *Instance) constructHelmArgs() []string {
 		args = append(args, "--values", value)
 	}
 
-	if len(i.Patches) > 0 || len(i.PatchesToEvaluate) > 0 {
-		args = append(args,
-			"--post-renderer", resolveKonduitBinary(),
-			"--post-renderer-args", "kustomize",
-		)
+	if i.usesKonduitPostRenderer() {
+		if i.HelmVersion >= HelmVersion4 {
+			args = append(args, "--post-renderer", PostRendererPluginName)
+		} else {
+			binary := resolveKonduitBinary()
+			i.log().Debug("resolved post-renderer", "path", binary)
+
+			args = append(args,
+				"--post-renderer", binary,
+				"--post-renderer-args", "kustomize",
+			)
+		}
 
-		if i.dir != "" {
+		if i.hasPatches() && i.dir != "" {
 			args = append(args, "--post-renderer-args", "--dir")
 			args = append(args, "--post-renderer-args", i.dir)
 		}
 
+		if i.hasPatches() && i.keepDir {
+			args = append(args, "--post-renderer-args", "--keep-intermediate")
+		}
+
+		for _, postRenderer := range i.PostRenderersBefore {
+			args = append(args, "--post-renderer-args", "--before")
+			args = append(args, "--post-renderer-args", postRenderer.String())
+		}
+
 		if i.PostRenderer != "" {
+			if i.HelmVersion >= HelmVersion4 {
+				args = append(args, "--post-renderer-args", "--post-renderer-plugin")
+			}
+
 			args = append(args, "--post-renderer-args", "--post-renderer")
 			args = append(args, "--post-renderer-args", i.PostRenderer)
 
@@ -82,6 +169,11 @@ func (i *Instance) constructHelmArgs() []string {
 				args = append(args, "--post-renderer-args", arg)
 			}
 		}
+
+		for _, postRenderer := range i.PostRenderersAfter {
+			args = append(args, "--post-renderer-args", "--after")
+			args = append(args, "--post-renderer-args", postRenderer.String())
+		}
 	} else if i.PostRenderer != "" {
 		args = append(args, "--post-renderer", i.PostRenderer)
 
@@ -93,10 +185,13 @@ func (i *Instance) constructHelmArgs() []string {
 	return args
 }
 
-const BinaryName = "konduit"
+const (
+	BinaryName             = "konduit"
+	PostRendererPluginName = "konduit-kustomize"
+)
 
 func resolveKonduitBinary() string {
-	if path, err := exec.LookPath(BinaryName); err == nil {
+	if path, err := osexec.LookPath(BinaryName); err == nil {
 		return path
 	}
 
@@ -109,21 +204,31 @@ func resolveKonduitBinary() string {
 	return BinaryName
 }
 
-func (i *Instance) Execute(ctx context.Context) error {
-	if i.dir == "" {
-		dir, err := os.MkdirTemp("", "konduit-*")
-		if err != nil {
-			return fmt.Errorf("create tmp dir: %w", err)
-		}
-		defer os.RemoveAll(dir)
-		i.dir = dir
+func (i *Instance) Execute(ctx context.Context) (err error) {
+	ctx, span := i.startSpan(ctx, "execute", attribute.String("konduit.engine", i.Engine().Name()))
+	defer func() { tracing.End(span, err) }()
+
+	cleanup, err := i.useWorkDir(ctx)
+	if err != nil {
+		return err
 	}
+	defer cleanup()
 
-	inv, err := i.Construct()
+	engine := i.Engine()
+
+	if err := engine.Prepare(ctx, i); err != nil {
+		return fmt.Errorf("prepare %s: %w", engine.Name(), err)
+	}
+
+	inv, err := i.Construct(ctx)
 	if err != nil {
 		return fmt.Errorf("construct invocation: %w", err)
 	}
 
+	defer func() {
+		err = errors.Join(err, i.writeArtifacts(inv))
+	}()
+
 	if err := inv.prepareHelm(i.dir); err != nil {
 		return err
 	}
@@ -132,7 +237,7 @@ func (i *Instance) Execute(ctx context.Context) error {
 		return err
 	}
 
-	if err := i.runner.Run(ctx, inv.Command, inv.Args); err != nil {
+	if err := engine.Run(ctx, i, inv); err != nil {
 		return fmt.Errorf("run invocation: %w", err)
 	}
 
@@ -140,16 +245,8 @@ func (i *Instance) Execute(ctx context.Context) error {
 }
 
 func (i *Invocation) prepareHelm(dir string) error {
-	if len(i.EvaluatedValues.ResultYAML) > 0 {
-		filename := filepath.Join(dir, ValuesFile)
-
-		file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
-		if err != nil {
-			return fmt.Errorf("create evaluated values file: %w", err)
-		}
-		defer file.Close()
-
-		if _, err := file.WriteString(i.EvaluatedValues.ResultYAML); err != nil {
+	if raw := i.EvaluatedValues.Raw(); len(raw) > 0 {
+		if err := workdir.WriteFile(filepath.Join(dir, ValuesFile), raw); err != nil {
 			return fmt.Errorf("write evaluated values file: %w", err)
 		}
 	}
@@ -158,28 +255,39 @@ func (i *Invocation) prepareHelm(dir string) error {
 }
 
 func (i *Invocation) prepareKustomize(dir string) error {
+	kustomization, err := i.kustomization()
+	if err != nil {
+		return err
+	}
+
+	if _, err := kustomize.WriteKustomization(dir, kustomization); err != nil {
+		return fmt.Errorf("write kustomization file: %w", err)
+	}
+
+	return nil
+}
+
+// kustomization defines a Kustomization with the evaluated patches, followed by
+// the static patches.
+func (i *Invocation) kustomization() (*types.Kustomization, error) {
 	patches := make([][]byte, 0)
 
-	if len(i.EvaluatedPatches.ResultYAML) > 0 {
-		patches = append(patches, []byte(i.EvaluatedPatches.ResultYAML))
+	if raw := i.EvaluatedPatches.Raw(); len(raw) > 0 {
+		patches = append(patches, raw)
 	}
 
 	for _, patch := range i.Patches {
 		content, err := os.ReadFile(patch)
 		if err != nil {
-			return fmt.Errorf("read patch file: %w", err)
+			return nil, fmt.Errorf("read patch file: %w", err)
 		}
 		patches = append(patches, content)
 	}
 
 	kustomization, err := kustomize.MakeDefinition(patches...)
 	if err != nil {
-		return fmt.Errorf("define kustomization: %w", err)
-	}
-
-	if _, err := kustomize.WriteKustomization(dir, kustomization); err != nil {
-		return fmt.Errorf("write kustomization file: %w", err)
+		return nil, fmt.Errorf("define kustomization: %w", err)
 	}
 
-	return nil
+	return kustomization, nil
 }
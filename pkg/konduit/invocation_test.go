@@ -1,15 +1,25 @@
 package konduit_test
 
 import (
+	"bytes"
+	"context"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
 
 	"github.com/jace-ys/konduit/internal/kustomize"
+	"github.com/jace-ys/konduit/pkg/cueval"
 	"github.com/jace-ys/konduit/pkg/konduit"
 	"github.com/jace-ys/konduit/pkg/konduit/mocks"
 )
@@ -171,6 +181,111 @@ func TestInstance_Construct(t *testing.T) {
 				},
 			},
 		},
+		// Chained post-renderers
+		{
+			name: "adds konduit post-renderer for chained post-renderers without patches",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release"},
+				PostRenderersBefore: []konduit.PostRenderer{
+					{Command: "helm-secrets", Args: []string{"post-render"}},
+				},
+			},
+			want: &konduit.Invocation{
+				Args: []string{
+					"template", "my-release",
+					"--post-renderer", konduitBinary,
+					"--post-renderer-args", "kustomize",
+					"--post-renderer-args", "--before",
+					"--post-renderer-args", "helm-secrets post-render",
+				},
+			},
+		},
+		{
+			name: "chains post-renderers before and after patches",
+			instance: &konduit.Instance{
+				HelmArgs:     []string{"template", "my-release"},
+				Patches:      []string{"patches.yaml"},
+				PostRenderer: "./bin/renderer",
+				PostRenderersBefore: []konduit.PostRenderer{
+					{Command: "helm-secrets", Args: []string{"post-render"}},
+					{Command: "./bin/pin digests"},
+				},
+				PostRenderersAfter: []konduit.PostRenderer{
+					{Command: "sed", Args: []string{"s/a b/c/"}},
+				},
+			},
+			want: &konduit.Invocation{
+				Args: []string{
+					"template", "my-release",
+					"--post-renderer", konduitBinary,
+					"--post-renderer-args", "kustomize",
+					"--post-renderer-args", "--dir",
+					"--post-renderer-args", "/tmp",
+					"--post-renderer-args", "--before",
+					"--post-renderer-args", "helm-secrets post-render",
+					"--post-renderer-args", "--before",
+					"--post-renderer-args", "'./bin/pin digests'",
+					"--post-renderer-args", "--post-renderer",
+					"--post-renderer-args", "./bin/renderer",
+					"--post-renderer-args", "--after",
+					"--post-renderer-args", "sed 's/a b/c/'",
+				},
+			},
+		},
+		// Helm 4
+		{
+			name: "adds konduit post-renderer plugin for patches with helm 4",
+			instance: &konduit.Instance{
+				HelmArgs:    []string{"template", "my-release", "my-chart"},
+				HelmVersion: konduit.HelmVersion4,
+				Patches:     []string{"p1.yaml"},
+			},
+			want: &konduit.Invocation{
+				Args: []string{
+					"template", "my-release", "my-chart",
+					"--post-renderer", konduit.PostRendererPluginName,
+					"--post-renderer-args", "--dir",
+					"--post-renderer-args", "/tmp",
+				},
+			},
+		},
+		{
+			name: "chains existing post-renderer plugin through konduit with helm 4",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"template", "my-release"},
+				HelmVersion:      konduit.HelmVersion4,
+				Patches:          []string{"patches.yaml"},
+				PostRenderer:     "my-plugin",
+				PostRendererArgs: []string{"--flag"},
+			},
+			want: &konduit.Invocation{
+				Args: []string{
+					"template", "my-release",
+					"--post-renderer", konduit.PostRendererPluginName,
+					"--post-renderer-args", "--dir",
+					"--post-renderer-args", "/tmp",
+					"--post-renderer-args", "--post-renderer-plugin",
+					"--post-renderer-args", "--post-renderer",
+					"--post-renderer-args", "my-plugin",
+					"--post-renderer-args", "--post-renderer-args",
+					"--post-renderer-args", "--flag",
+				},
+			},
+		},
+		{
+			name: "passes through post-renderer plugin without patches with helm 4",
+			instance: &konduit.Instance{
+				HelmArgs:     []string{"template", "my-release"},
+				HelmVersion:  konduit.HelmVersion4,
+				PostRenderer: "my-plugin",
+			},
+			want: &konduit.Invocation{
+				Args: []string{
+					"template", "my-release",
+					"--post-renderer", "my-plugin",
+				},
+			},
+		},
 		// Combined
 		{
 			name: "combines values and patches",
@@ -221,7 +336,7 @@ func TestInstance_Construct(t *testing.T) {
 			konduit.WithEvaluator(konduit.NewNoopEvaluator()).Apply(tt.instance)
 			konduit.WithWorkDir("/tmp").Apply(tt.instance)
 
-			actual, err := tt.instance.Construct()
+			actual, err := tt.instance.Construct(t.Context())
 			require.NoError(t, err)
 
 			assert.Equal(t, konduit.DefaultHelmCommand, actual.Command)
@@ -249,11 +364,11 @@ func TestInstance_Construct_WithEvaluator(t *testing.T) {
 				ValuesToEvaluate: []string{"values.cue"},
 			},
 			setupMock: func(m *mocks.MockEvaluator) {
-				m.EXPECT().Evaluate([]string{"values.cue"}).Return([]byte("key: value\n"), nil)
+				m.EXPECT().Evaluate(mock.Anything, []string{"values.cue"}).Return(mustYAMLResult("key: value\n"), nil)
 			},
 			wantEvaluatedValues: &konduit.Evaluation{
-				Files:      []string{"values.cue"},
-				ResultYAML: "key: value\n",
+				Files:  []string{"values.cue"},
+				Result: mustYAMLResult("key: value\n"),
 			},
 			wantEvaluatedPatches: &konduit.Evaluation{},
 		},
@@ -263,12 +378,12 @@ func TestInstance_Construct_WithEvaluator(t *testing.T) {
 				PatchesToEvaluate: []string{"patches.cue"},
 			},
 			setupMock: func(m *mocks.MockEvaluator) {
-				m.EXPECT().Evaluate([]string{"patches.cue"}).Return([]byte("key: value\n"), nil)
+				m.EXPECT().Evaluate(mock.Anything, []string{"patches.cue"}).Return(mustYAMLResult("key: value\n"), nil)
 			},
 			wantEvaluatedValues: &konduit.Evaluation{},
 			wantEvaluatedPatches: &konduit.Evaluation{
-				Files:      []string{"patches.cue"},
-				ResultYAML: "key: value\n",
+				Files:  []string{"patches.cue"},
+				Result: mustYAMLResult("key: value\n"),
 			},
 		},
 		{
@@ -278,16 +393,16 @@ func TestInstance_Construct_WithEvaluator(t *testing.T) {
 				PatchesToEvaluate: []string{"patches.cue"},
 			},
 			setupMock: func(m *mocks.MockEvaluator) {
-				m.EXPECT().Evaluate([]string{"values.cue"}).Return([]byte("key: value\n"), nil)
-				m.EXPECT().Evaluate([]string{"patches.cue"}).Return([]byte("key: value\n"), nil)
+				m.EXPECT().Evaluate(mock.Anything, []string{"values.cue"}).Return(mustYAMLResult("key: value\n"), nil)
+				m.EXPECT().Evaluate(mock.Anything, []string{"patches.cue"}).Return(mustYAMLResult("key: value\n"), nil)
 			},
 			wantEvaluatedValues: &konduit.Evaluation{
-				Files:      []string{"values.cue"},
-				ResultYAML: "key: value\n",
+				Files:  []string{"values.cue"},
+				Result: mustYAMLResult("key: value\n"),
 			},
 			wantEvaluatedPatches: &konduit.Evaluation{
-				Files:      []string{"patches.cue"},
-				ResultYAML: "key: value\n",
+				Files:  []string{"patches.cue"},
+				Result: mustYAMLResult("key: value\n"),
 			},
 		},
 		{
@@ -296,7 +411,7 @@ func TestInstance_Construct_WithEvaluator(t *testing.T) {
 				ValuesToEvaluate: []string{"invalid.cue"},
 			},
 			setupMock: func(m *mocks.MockEvaluator) {
-				m.EXPECT().Evaluate([]string{"invalid.cue"}).Return(nil, assert.AnError)
+				m.EXPECT().Evaluate(mock.Anything, []string{"invalid.cue"}).Return(nil, assert.AnError)
 			},
 			wantErr: "evaluate values",
 		},
@@ -306,10 +421,22 @@ func TestInstance_Construct_WithEvaluator(t *testing.T) {
 				PatchesToEvaluate: []string{"invalid.cue"},
 			},
 			setupMock: func(m *mocks.MockEvaluator) {
-				m.EXPECT().Evaluate([]string{"invalid.cue"}).Return(nil, assert.AnError)
+				m.EXPECT().Evaluate(mock.Anything, []string{"invalid.cue"}).Return(nil, assert.AnError)
 			},
 			wantErr: "evaluate patches",
 		},
+		{
+			name: "returns errors from both values and patches",
+			instance: &konduit.Instance{
+				ValuesToEvaluate:  []string{"values.cue"},
+				PatchesToEvaluate: []string{"patches.cue"},
+			},
+			setupMock: func(m *mocks.MockEvaluator) {
+				m.EXPECT().Evaluate(mock.Anything, []string{"values.cue"}).Return(nil, assert.AnError)
+				m.EXPECT().Evaluate(mock.Anything, []string{"patches.cue"}).Return(nil, assert.AnError)
+			},
+			wantErr: "evaluate values: " + assert.AnError.Error() + "\nevaluate patches: " + assert.AnError.Error(),
+		},
 	}
 
 	for _, tt := range tests {
@@ -323,10 +450,13 @@ func TestInstance_Construct_WithEvaluator(t *testing.T) {
 			tt.setupMock(eval)
 			konduit.WithEvaluator(eval).Apply(tt.instance)
 
-			actual, err := tt.instance.Construct()
+			actual, err := tt.instance.Construct(t.Context())
 			if tt.wantErr != "" {
 				require.Error(t, err)
 				require.ErrorContains(t, err, tt.wantErr)
+
+				var evalErr *konduit.EvaluationError
+				assert.ErrorAs(t, err, &evalErr)
 				return
 			}
 
@@ -337,6 +467,179 @@ func TestInstance_Construct_WithEvaluator(t *testing.T) {
 	}
 }
 
+func TestInstance_Construct_WithEvalTimeout(t *testing.T) {
+	t.Parallel()
+
+	instance := &konduit.Instance{
+		HelmCommand:      konduit.DefaultHelmCommand,
+		HelmArgs:         []string{"template", "my-release"},
+		ValuesToEvaluate: []string{"values.cue"},
+	}
+
+	eval := mocks.NewMockEvaluator(t)
+	eval.EXPECT().
+		Evaluate(mock.Anything, []string{"values.cue"}).
+		RunAndReturn(func(ctx context.Context, files []string) (*konduit.Result, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		})
+
+	konduit.WithEvaluator(eval).Apply(instance)
+	konduit.WithEvalTimeout(time.Millisecond).Apply(instance)
+
+	_, err := instance.Construct(t.Context())
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.ErrorContains(t, err, "evaluate values")
+}
+
+func TestInstance_Construct_WithSharedEvaluator(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"cue.mod/module.cue":  "module: \"example.com/app@v0\"\nlanguage: version: \"v0.15.0\"\n",
+		"lib/lib.cue":         "package lib\n\n#Name: \"web\"\n",
+		"release/values.cue":  "package values\n\nimport \"example.com/app/lib\"\n\nname: lib.#Name\n",
+		"release/patches.cue": "package patches\n\nimport \"example.com/app/lib\"\n\nnamePrefix: lib.#Name + \"-\"\n",
+		"release/invalid.cue": "replicas: 1 & 2\n",
+	}
+	for name, data := range files {
+		filename := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(filename), 0o755))
+		require.NoError(t, os.WriteFile(filename, []byte(data), 0o644))
+	}
+
+	file := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+
+	tests := []struct {
+		name        string
+		values      []string
+		patches     []string
+		wantValues  string
+		wantPatches string
+		wantErr     []string
+	}{
+		{
+			name:        "loads the module once for values and patches",
+			values:      []string{file("release/values.cue")},
+			patches:     []string{file("release/patches.cue")},
+			wantValues:  "name: web\n",
+			wantPatches: "namePrefix: web-\n",
+		},
+		{
+			name:    "reports the errors of values and patches together",
+			values:  []string{file("release/values.cue"), file("release/invalid.cue")},
+			patches: []string{file("release/invalid.cue"), file("release/patches.cue")},
+			wantErr: []string{"evaluate values: evaluate CUE: build instance: replicas: conflicting values", "evaluate patches: evaluate CUE: build instance: replicas: conflicting values"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			instance := &konduit.Instance{
+				HelmCommand:       konduit.DefaultHelmCommand,
+				HelmArgs:          []string{"template", "my-release"},
+				ValuesToEvaluate:  tt.values,
+				PatchesToEvaluate: tt.patches,
+			}
+			konduit.WithEvaluator(konduit.NewCUEEvaluator(
+				cueval.WithLoadDir(dir),
+				cueval.WithTracerProvider(provider),
+			)).Apply(instance)
+
+			actual, err := instance.Construct(t.Context())
+			if len(tt.wantErr) > 0 {
+				var evalErr *konduit.EvaluationError
+				require.ErrorAs(t, err, &evalErr)
+				for _, want := range tt.wantErr {
+					assert.ErrorContains(t, err, want)
+				}
+			} else {
+				require.NoError(t, err)
+				assert.Equal(t, tt.wantValues, string(actual.EvaluatedValues.Raw()))
+				assert.Equal(t, tt.wantPatches, string(actual.EvaluatedPatches.Raw()))
+			}
+
+			loads := 0
+			for _, span := range recorder.Ended() {
+				if span.Name() == "cue load" {
+					loads++
+				}
+			}
+			assert.Equal(t, 1, loads)
+		})
+	}
+}
+
+func TestInstance_Construct_WithKeepWorkDir(t *testing.T) {
+	t.Parallel()
+
+	konduitBinary, err := os.Executable()
+	require.NoError(t, err)
+
+	instance := &konduit.Instance{
+		HelmCommand: konduit.DefaultHelmCommand,
+		HelmArgs:    []string{"template", "my-release"},
+		Patches:     []string{"patches.yaml"},
+	}
+
+	konduit.WithEvaluator(konduit.NewNoopEvaluator()).Apply(instance)
+	konduit.WithWorkDir("/tmp").Apply(instance)
+	konduit.WithKeepWorkDir(true).Apply(instance)
+
+	actual, err := instance.Construct(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{
+		"template", "my-release",
+		"--post-renderer", konduitBinary,
+		"--post-renderer-args", "kustomize",
+		"--post-renderer-args", "--dir",
+		"--post-renderer-args", "/tmp",
+		"--post-renderer-args", "--keep-intermediate",
+	}, actual.Args)
+}
+
+func TestInstance_Construct_WithLogger(t *testing.T) {
+	t.Parallel()
+
+	instance := &konduit.Instance{
+		HelmCommand:      konduit.DefaultHelmCommand,
+		HelmArgs:         []string{"template", "my-release"},
+		ValuesToEvaluate: []string{"values.cue"},
+	}
+
+	eval := mocks.NewMockEvaluator(t)
+	eval.EXPECT().Evaluate(mock.Anything, []string{"values.cue"}).Return(mustYAMLResult("replicas: 1\n"), nil)
+
+	var logs bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, attr slog.Attr) slog.Attr {
+			if attr.Key == slog.TimeKey || attr.Key == "duration" {
+				return slog.Attr{}
+			}
+			return attr
+		},
+	}))
+
+	konduit.WithEvaluator(eval).Apply(instance)
+	konduit.WithWorkDir("/tmp").Apply(instance)
+	konduit.WithLogger(logger).Apply(instance)
+
+	_, err := instance.Construct(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, `level=DEBUG msg="evaluated values" files=[values.cue]
+level=DEBUG msg="constructed invocation" engine=helm command=helm args="[template my-release --values /tmp/evaluated.yaml]"
+`, logs.String())
+}
+
 func TestInstance_Execute(t *testing.T) {
 	t.Parallel()
 
@@ -380,7 +683,7 @@ func TestInstance_Execute(t *testing.T) {
 				ValuesToEvaluate: []string{"values.cue"},
 			},
 			setupMockEvaluator: func(m *mocks.MockEvaluator) {
-				m.EXPECT().Evaluate([]string{"values.cue"}).Return([]byte("key: value\n"), nil)
+				m.EXPECT().Evaluate(mock.Anything, []string{"values.cue"}).Return(mustYAMLResult("key: value\n"), nil)
 			},
 			setupMockRunner: func(m *mocks.MockRunner) {
 				m.EXPECT().Run(mock.Anything, konduit.DefaultHelmCommand, mock.Anything).Return(nil)
@@ -393,10 +696,11 @@ func TestInstance_Execute(t *testing.T) {
 			name: "writes evaluated patches to file",
 			instance: &konduit.Instance{
 				HelmArgs:          []string{"template", "my-release"},
+				HelmVersion:       konduit.HelmVersion3,
 				PatchesToEvaluate: []string{"patches.cue"},
 			},
 			setupMockEvaluator: func(m *mocks.MockEvaluator) {
-				m.EXPECT().Evaluate([]string{"patches.cue"}).Return([]byte("namePrefix: test-"), nil)
+				m.EXPECT().Evaluate(mock.Anything, []string{"patches.cue"}).Return(mustYAMLResult("namePrefix: test-"), nil)
 			},
 			setupMockRunner: func(m *mocks.MockRunner) {
 				m.EXPECT().Run(mock.Anything, konduit.DefaultHelmCommand, mock.Anything).Return(nil)
@@ -417,11 +721,25 @@ namePrefix: test-
 				ValuesToEvaluate: []string{"invalid.cue"},
 			},
 			setupMockEvaluator: func(m *mocks.MockEvaluator) {
-				m.EXPECT().Evaluate([]string{"invalid.cue"}).Return(nil, assert.AnError)
+				m.EXPECT().Evaluate(mock.Anything, []string{"invalid.cue"}).Return(nil, assert.AnError)
 			},
 			setupMockRunner: func(m *mocks.MockRunner) {},
 			wantErr:         "evaluate values",
 		},
+		{
+			name: "returns error when helm version detection fails",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release"},
+				Patches:  []string{"patches.yaml"},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			setupMockRunner: func(m *mocks.MockRunner) {
+				m.EXPECT().
+					Run(mock.Anything, konduit.DefaultHelmCommand, []string{"version", "--template", "{{ .Version }}"}, mock.Anything).
+					Return(assert.AnError)
+			},
+			wantErr: "resolve helm version",
+		},
 		{
 			name: "returns error when runner fails",
 			instance: &konduit.Instance{
@@ -468,3 +786,106 @@ namePrefix: test-
 		})
 	}
 }
+
+func TestInstance_Execute_WithTracerProvider(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name            string
+		instance        *konduit.Instance
+		setupMockRunner func(*mocks.MockRunner)
+		wantSpans       []string
+		wantErrSpans    []string
+	}{
+		{
+			name: "traces evaluation and the helm run",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"template", "my-release"},
+				ValuesToEvaluate: []string{"values.cue"},
+			},
+			setupMockRunner: func(m *mocks.MockRunner) {
+				m.EXPECT().
+					Run(mock.MatchedBy(func(ctx context.Context) bool {
+						return trace.SpanContextFromContext(ctx).IsValid()
+					}), konduit.DefaultHelmCommand, mock.Anything).
+					Return(nil)
+			},
+			wantSpans: []string{
+				"execute",
+				"execute/evaluate values",
+				"execute/helm run",
+			},
+		},
+		{
+			name: "records the error of a failed stage",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"template", "my-release"},
+				ValuesToEvaluate: []string{"values.cue"},
+			},
+			setupMockRunner: func(m *mocks.MockRunner) {
+				m.EXPECT().Run(mock.Anything, konduit.DefaultHelmCommand, mock.Anything).Return(assert.AnError)
+			},
+			wantSpans: []string{
+				"execute",
+				"execute/evaluate values",
+				"execute/helm run",
+			},
+			wantErrSpans: []string{
+				"execute",
+				"execute/helm run",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			recorder := tracetest.NewSpanRecorder()
+			provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+			tt.instance.HelmCommand = konduit.DefaultHelmCommand
+			konduit.WithWorkDir(t.TempDir()).Apply(tt.instance)
+			konduit.WithTracerProvider(provider).Apply(tt.instance)
+
+			eval := mocks.NewMockEvaluator(t)
+			eval.EXPECT().Evaluate(mock.Anything, []string{"values.cue"}).Return(mustYAMLResult("key: value\n"), nil)
+			konduit.WithEvaluator(eval).Apply(tt.instance)
+
+			runner := mocks.NewMockRunner(t)
+			tt.setupMockRunner(runner)
+			konduit.WithRunner(runner).Apply(tt.instance)
+
+			_ = tt.instance.Execute(t.Context())
+
+			spans, errSpans := spanPaths(recorder.Ended())
+			assert.Equal(t, tt.wantSpans, spans)
+			assert.Equal(t, tt.wantErrSpans, errSpans)
+		})
+	}
+}
+
+// spanPaths returns the path of each span from its root, sorted, and of the
+// spans that recorded an error.
+func spanPaths(spans []sdktrace.ReadOnlySpan) (paths []string, errPaths []string) {
+	byID := make(map[trace.SpanID]sdktrace.ReadOnlySpan)
+	for _, span := range spans {
+		byID[span.SpanContext().SpanID()] = span
+	}
+
+	for _, span := range spans {
+		path := span.Name()
+		for parent, ok := byID[span.Parent().SpanID()]; ok; parent, ok = byID[parent.Parent().SpanID()] {
+			path = parent.Name() + "/" + path
+		}
+
+		paths = append(paths, path)
+		if span.Status().Code == codes.Error {
+			errPaths = append(errPaths, path)
+		}
+	}
+
+	slices.Sort(paths)
+	slices.Sort(errPaths)
+	return paths, errPaths
+}
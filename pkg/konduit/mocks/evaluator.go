@@ -5,6 +5,9 @@
 package mocks
 
 import (
+	"context"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
 	mock "github.com/stretchr/testify/mock"
 )
 
@@ -36,27 +39,27 @@ func (_m *MockEvaluator) EXPECT() *MockEvaluator_Expecter {
 }
 
 // Evaluate provides a mock function for the type MockEvaluator
-func (_mock *MockEvaluator) Evaluate(files []string) ([]byte, error) {
-	ret := _mock.Called(files)
+func (_mock *MockEvaluator) Evaluate(ctx context.Context, files []string) (*konduit.Result, error) {
+	ret := _mock.Called(ctx, files)
 
 	if len(ret) == 0 {
 		panic("no return value specified for Evaluate")
 	}
 
-	var r0 []byte
+	var r0 *konduit.Result
 	var r1 error
-	if returnFunc, ok := ret.Get(0).(func([]string) ([]byte, error)); ok {
-		return returnFunc(files)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string) (*konduit.Result, error)); ok {
+		return returnFunc(ctx, files)
 	}
-	if returnFunc, ok := ret.Get(0).(func([]string) []byte); ok {
-		r0 = returnFunc(files)
+	if returnFunc, ok := ret.Get(0).(func(context.Context, []string) *konduit.Result); ok {
+		r0 = returnFunc(ctx, files)
 	} else {
 		if ret.Get(0) != nil {
-			r0 = ret.Get(0).([]byte)
+			r0 = ret.Get(0).(*konduit.Result)
 		}
 	}
-	if returnFunc, ok := ret.Get(1).(func([]string) error); ok {
-		r1 = returnFunc(files)
+	if returnFunc, ok := ret.Get(1).(func(context.Context, []string) error); ok {
+		r1 = returnFunc(ctx, files)
 	} else {
 		r1 = ret.Error(1)
 	}
@@ -69,30 +72,36 @@ type MockEvaluator_Evaluate_Call struct {
 }
 
 // Evaluate is a helper method to define mock.On call
+//   - ctx context.Context
 //   - files []string
-func (_e *MockEvaluator_Expecter) Evaluate(files interface{}) *MockEvaluator_Evaluate_Call {
-	return &MockEvaluator_Evaluate_Call{Call: _e.mock.On("Evaluate", files)}
+func (_e *MockEvaluator_Expecter) Evaluate(ctx interface{}, files interface{}) *MockEvaluator_Evaluate_Call {
+	return &MockEvaluator_Evaluate_Call{Call: _e.mock.On("Evaluate", ctx, files)}
 }
 
-func (_c *MockEvaluator_Evaluate_Call) Run(run func(files []string)) *MockEvaluator_Evaluate_Call {
+func (_c *MockEvaluator_Evaluate_Call) Run(run func(ctx context.Context, files []string)) *MockEvaluator_Evaluate_Call {
 	_c.Call.Run(func(args mock.Arguments) {
-		var arg0 []string
+		var arg0 context.Context
 		if args[0] != nil {
-			arg0 = args[0].([]string)
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 []string
+		if args[1] != nil {
+			arg1 = args[1].([]string)
 		}
 		run(
 			arg0,
+			arg1,
 		)
 	})
 	return _c
 }
 
-func (_c *MockEvaluator_Evaluate_Call) Return(result []byte, err error) *MockEvaluator_Evaluate_Call {
+func (_c *MockEvaluator_Evaluate_Call) Return(result *konduit.Result, err error) *MockEvaluator_Evaluate_Call {
 	_c.Call.Return(result, err)
 	return _c
 }
 
-func (_c *MockEvaluator_Evaluate_Call) RunAndReturn(run func(files []string) ([]byte, error)) *MockEvaluator_Evaluate_Call {
+func (_c *MockEvaluator_Evaluate_Call) RunAndReturn(run func(ctx context.Context, files []string) (*konduit.Result, error)) *MockEvaluator_Evaluate_Call {
 	_c.Call.Return(run)
 	return _c
 }
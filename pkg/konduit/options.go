@@ -1,5 +1,13 @@
 package konduit
 
+import (
+	"io"
+	"log/slog"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
 type Option interface {
 	Apply(i *Instance)
 }
@@ -8,12 +16,25 @@ type OptionFunc func(*Instance)
 
 func (o OptionFunc) Apply(i *Instance) { o(i) }
 
-func WithHelmCommand(command string) Option {
+// WithCommand sets the command run by the engine, such as the path to a
+// Helm or Timoni executable.
+func WithCommand(command string) Option {
 	return OptionFunc(func(i *Instance) {
 		i.HelmCommand = command
 	})
 }
 
+// WithHelmCommand is an alias of WithCommand.
+func WithHelmCommand(command string) Option {
+	return WithCommand(command)
+}
+
+func WithHelmVersion(version int) Option {
+	return OptionFunc(func(i *Instance) {
+		i.HelmVersion = version
+	})
+}
+
 func WithPatches(patches []string) Option {
 	return OptionFunc(func(i *Instance) {
 		i.patchesOpt = patches
@@ -26,12 +47,80 @@ func WithWorkDir(dir string) Option {
 	})
 }
 
+// WithKeepWorkDir keeps the work dir after Execute or Render returns, along
+// with intermediate manifests and a description of each file in it, for
+// debugging. A work dir set with WithWorkDir is never removed.
+func WithKeepWorkDir(keep bool) Option {
+	return OptionFunc(func(i *Instance) {
+		i.keepDir = keep
+	})
+}
+
 func WithModeStrict(strict bool) Option {
 	return OptionFunc(func(i *Instance) {
 		i.strict = strict
 	})
 }
 
+// WithEvalTimeout limits how long evaluating values and patches may take.
+func WithEvalTimeout(timeout time.Duration) Option {
+	return OptionFunc(func(i *Instance) {
+		i.evalTimeout = timeout
+	})
+}
+
+// WithPostRenderersBefore adds post-renderers that run on the rendered
+// manifests before patches are applied.
+func WithPostRenderersBefore(postRenderers ...PostRenderer) Option {
+	return OptionFunc(func(i *Instance) {
+		i.PostRenderersBefore = append(i.PostRenderersBefore, postRenderers...)
+	})
+}
+
+// WithPostRenderersAfter adds post-renderers that run after patches are
+// applied, and after any post-renderer passed to Helm with --post-renderer.
+func WithPostRenderersAfter(postRenderers ...PostRenderer) Option {
+	return OptionFunc(func(i *Instance) {
+		i.PostRenderersAfter = append(i.PostRenderersAfter, postRenderers...)
+	})
+}
+
+// WithLogger logs how files are classified, the constructed arguments and the
+// commands that are run at debug level. The logger of a CUEEvaluator is set
+// separately, with cueval.WithLogger, and so is that of a Runner passed to
+// WithRunner, with RunWithLogger.
+func WithLogger(logger *slog.Logger) Option {
+	return OptionFunc(func(i *Instance) {
+		if logger != nil {
+			i.logger = logger
+		}
+	})
+}
+
+// WithTracerProvider traces evaluation, rendering and post-rendering with
+// spans from provider, instead of the global tracer provider. The trace
+// context is passed on to child processes in the TRACEPARENT environment
+// variable.
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return OptionFunc(func(i *Instance) {
+		if provider != nil {
+			i.tracer = provider
+		}
+	})
+}
+
+func WithStdout(stdout io.Writer) Option {
+	return OptionFunc(func(i *Instance) {
+		i.stdout = stdout
+	})
+}
+
+func WithEngine(engine Engine) Option {
+	return OptionFunc(func(i *Instance) {
+		i.engine = engine
+	})
+}
+
 func WithEvaluator(evaluator Evaluator) Option {
 	return OptionFunc(func(i *Instance) {
 		i.evaluator = evaluator
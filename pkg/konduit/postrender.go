@@ -0,0 +1,120 @@
+package konduit
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// PostRenderer is a command that manifests are piped through, like a Helm
+// post-renderer: it reads manifests from stdin and writes them to stdout.
+type PostRenderer struct {
+	Command string   `json:"command"`
+	Args    []string `json:"args,omitempty"`
+}
+
+// ParsePostRenderer parses a post-renderer from a command line, such as
+// "helm-secrets post-render". Words are separated by spaces, and may be quoted
+// as in a shell, but variables and other expansions are not supported.
+func ParsePostRenderer(cmdline string) (PostRenderer, error) {
+	words, err := splitWords(cmdline)
+	if err != nil {
+		return PostRenderer{}, fmt.Errorf("parse post-renderer %q: %w", cmdline, err)
+	}
+
+	if len(words) == 0 {
+		return PostRenderer{}, errors.New("parse post-renderer: no command")
+	}
+
+	return PostRenderer{Command: words[0], Args: words[1:]}, nil
+}
+
+// String returns the command line of the post-renderer, quoted so that
+// ParsePostRenderer returns it unchanged.
+func (p PostRenderer) String() string {
+	words := make([]string, 0, len(p.Args)+1)
+	for _, word := range append([]string{p.Command}, p.Args...) {
+		words = append(words, quoteWord(word))
+	}
+	return strings.Join(words, " ")
+}
+
+// splitWords splits a command line into words, removing quotes and escapes.
+func splitWords(cmdline string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+
+	for n := 0; n < len(cmdline); n++ {
+		c := cmdline[n]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+			continue
+
+		case c == '\\':
+			if n+1 == len(cmdline) {
+				return nil, errors.New("trailing backslash")
+			}
+			n++
+			word.WriteByte(cmdline[n])
+
+		case c == '\'':
+			end := strings.IndexByte(cmdline[n+1:], '\'')
+			if end < 0 {
+				return nil, errors.New("unterminated single quote")
+			}
+			word.WriteString(cmdline[n+1 : n+1+end])
+			n += end + 1
+
+		case c == '"':
+			n++
+			for ; n < len(cmdline) && cmdline[n] != '"'; n++ {
+				if cmdline[n] == '\\' && n+1 < len(cmdline) && strings.IndexByte(`"\$`+"`", cmdline[n+1]) >= 0 {
+					n++
+				}
+				word.WriteByte(cmdline[n])
+			}
+			if n == len(cmdline) {
+				return nil, errors.New("unterminated double quote")
+			}
+
+		default:
+			word.WriteByte(c)
+		}
+
+		inWord = true
+	}
+
+	if inWord {
+		words = append(words, word.String())
+	}
+
+	return words, nil
+}
+
+func (i *Instance) hasPostRenderers() bool {
+	return len(i.PostRenderersBefore) > 0 || len(i.PostRenderersAfter) > 0
+}
+
+// usesKonduitPostRenderer reports whether Helm runs the Konduit post-renderer,
+// to apply patches or to run a chain of post-renderers.
+func (i *Instance) usesKonduitPostRenderer() bool {
+	return i.hasPatches() || i.hasPostRenderers()
+}
+
+// postRenderersAfter returns the post-renderers that run after patches are
+// applied, starting with the one passed to Helm with --post-renderer.
+func (i *Instance) postRenderersAfter() []PostRenderer {
+	if i.PostRenderer == "" {
+		return i.PostRenderersAfter
+	}
+
+	helm := PostRenderer{Command: i.PostRenderer, Args: i.PostRendererArgs}
+	return append([]PostRenderer{helm}, i.PostRenderersAfter...)
+}
@@ -0,0 +1,76 @@
+package konduit_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+func TestParsePostRenderer(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		cmdline string
+		want    konduit.PostRenderer
+		wantErr string
+	}{
+		{
+			name:    "parses command without args",
+			cmdline: "./bin/renderer",
+			want:    konduit.PostRenderer{Command: "./bin/renderer", Args: []string{}},
+		},
+		{
+			name:    "splits args on whitespace",
+			cmdline: "  helm-secrets\tpost-render  --quiet ",
+			want:    konduit.PostRenderer{Command: "helm-secrets", Args: []string{"post-render", "--quiet"}},
+		},
+		{
+			name:    "removes quotes and escapes",
+			cmdline: `sed 's/a b/c/' "say \"hi\" \$HOME" it\'s`,
+			want:    konduit.PostRenderer{Command: "sed", Args: []string{"s/a b/c/", `say "hi" $HOME`, "it's"}},
+		},
+		{
+			name:    "keeps empty quoted args",
+			cmdline: `renderer '' ""`,
+			want:    konduit.PostRenderer{Command: "renderer", Args: []string{"", ""}},
+		},
+		{
+			name:    "returns error for empty command line",
+			cmdline: " ",
+			wantErr: "no command",
+		},
+		{
+			name:    "returns error for unterminated quote",
+			cmdline: "sed 's/a/b/",
+			wantErr: "unterminated single quote",
+		},
+		{
+			name:    "returns error for trailing backslash",
+			cmdline: `renderer \`,
+			wantErr: "trailing backslash",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			actual, err := konduit.ParsePostRenderer(tt.cmdline)
+			if tt.wantErr != "" {
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, actual)
+
+			roundTrip, err := konduit.ParsePostRenderer(actual.String())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, roundTrip)
+		})
+	}
+}
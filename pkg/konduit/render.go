@@ -0,0 +1,330 @@
+package konduit
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/pflag"
+	"go.opentelemetry.io/otel/attribute"
+	"helm.sh/helm/v3/pkg/action"
+	"helm.sh/helm/v3/pkg/chart/loader"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/cli"
+	"helm.sh/helm/v3/pkg/cli/values"
+	"helm.sh/helm/v3/pkg/getter"
+	"helm.sh/helm/v3/pkg/registry"
+	"helm.sh/helm/v3/pkg/release"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/internal/kustomize"
+	"github.com/jace-ys/konduit/internal/tracing"
+)
+
+const DefaultReleaseName = "release-name"
+
+func (i *Instance) Render(ctx context.Context) (_ []byte, err error) {
+	ctx, span := i.startSpan(ctx, "render")
+	defer func() { tracing.End(span, err) }()
+
+	if _, ok := i.Engine().(*HelmEngine); !ok {
+		return nil, errors.New("in-process rendering only supports the helm engine")
+	}
+
+	if len(i.HelmArgs) == 0 || i.HelmArgs[0] != "template" {
+		return nil, errors.New("in-process rendering only supports the template command")
+	}
+
+	opts, err := parseTemplateArgs(i.HelmArgs[1:])
+	if err != nil {
+		return nil, fmt.Errorf("parse template args: %w", err)
+	}
+
+	cleanup, err := i.useWorkDir(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+
+	inv, err := i.Construct(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("construct invocation: %w", err)
+	}
+
+	defer func() {
+		err = errors.Join(err, i.writeArtifacts(inv))
+	}()
+
+	if err := inv.prepareHelm(i.dir); err != nil {
+		return nil, err
+	}
+
+	if len(inv.EvaluatedValues.Raw()) > 0 {
+		opts.values.ValueFiles = append(opts.values.ValueFiles, filepath.Join(i.dir, ValuesFile))
+	}
+	opts.values.ValueFiles = append(opts.values.ValueFiles, i.Values...)
+
+	rel, err := i.renderChart(ctx, opts, inv)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifests bytes.Buffer
+	fmt.Fprintln(&manifests, strings.TrimSpace(rel.Manifest))
+
+	if !opts.install.DisableHooks {
+		for _, hook := range rel.Hooks {
+			if opts.skipTests && slices.Contains(hook.Events, release.HookTest) {
+				continue
+			}
+			fmt.Fprintf(&manifests, "---\n# Source: %s\n%s\n", hook.Path, hook.Manifest)
+		}
+	}
+
+	return manifests.Bytes(), nil
+}
+
+// renderChart renders the chart in-process, applying patches and running the
+// post-renderers in the same way as the Konduit post-renderer.
+func (i *Instance) renderChart(ctx context.Context, opts *templateOptions, inv *Invocation) (_ *release.Release, err error) {
+	ctx, span := i.startSpan(ctx, "helm render", attribute.StringSlice("konduit.chart", opts.args))
+	defer func() { tracing.End(span, err) }()
+
+	if i.hasPatches() {
+		if err := inv.prepareKustomize(i.dir); err != nil {
+			return nil, err
+		}
+	}
+
+	if i.usesKonduitPostRenderer() || i.PostRenderer != "" {
+		opts.install.PostRenderer = i.postRenderer(ctx)
+	}
+
+	start := time.Now()
+	rel, err := opts.run(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("render chart: %w", err)
+	}
+	i.log().Debug("rendered chart in-process", "chart", opts.args, "duration", time.Since(start))
+
+	return rel, nil
+}
+
+type postRenderFunc func(manifests *bytes.Buffer) (*bytes.Buffer, error)
+
+func (f postRenderFunc) Run(manifests *bytes.Buffer) (*bytes.Buffer, error) { return f(manifests) }
+
+// postRenderer pipes the manifests rendered in-process through the post-render
+// stages, in the same way as the Konduit post-renderer.
+func (i *Instance) postRenderer(ctx context.Context) postRenderFunc {
+	return func(manifests *bytes.Buffer) (_ *bytes.Buffer, err error) {
+		ctx, span := i.startSpan(ctx, "post-render")
+		defer func() { tracing.End(span, err) }()
+
+		var stdout bytes.Buffer
+		if err := exec.Pipeline(ctx, manifests, &stdout, i.postRenderStages()...); err != nil {
+			return nil, err
+		}
+
+		return &stdout, nil
+	}
+}
+
+// postRenderStages returns the post-renderers that run before patches are
+// applied, Kustomize if there are patches, and the post-renderers that run
+// after them.
+func (i *Instance) postRenderStages() []exec.Stage {
+	stages := make([]exec.Stage, 0)
+
+	for _, postRenderer := range i.PostRenderersBefore {
+		stages = append(stages, i.postRendererStage(postRenderer))
+	}
+
+	if i.hasPatches() {
+		stages = append(stages, i.kustomizeStage)
+	}
+
+	for _, postRenderer := range i.postRenderersAfter() {
+		stages = append(stages, i.postRendererStage(postRenderer))
+	}
+
+	return stages
+}
+
+func (i *Instance) postRendererStage(postRenderer PostRenderer) exec.Stage {
+	return func(ctx context.Context, stdin io.Reader, stdout io.Writer) (err error) {
+		ctx, span := i.startSpan(ctx, "post-renderer", attribute.String("konduit.command", postRenderer.Command))
+		defer func() { tracing.End(span, err) }()
+
+		opts := []exec.RunOption{exec.WithStdin(stdin), exec.WithStdout(stdout)}
+		if err := i.runner.Run(ctx, postRenderer.Command, postRenderer.Args, opts...); err != nil {
+			return fmt.Errorf("run post-renderer %s: %w", postRenderer.Command, err)
+		}
+
+		return nil
+	}
+}
+
+// kustomizeStage applies the patches in the work dir to the manifests
+// in-process.
+func (i *Instance) kustomizeStage(ctx context.Context, stdin io.Reader, stdout io.Writer) error {
+	filename, err := kustomize.WriteManifests(i.dir, stdin)
+	if err != nil {
+		return fmt.Errorf("write manifests file: %w", err)
+	}
+	defer i.removeIntermediate(filename)
+
+	manifests, err := i.buildKustomization(ctx, i.dir)
+	if err != nil {
+		return err
+	}
+
+	if err := i.writeOutput(i.dir, manifests); err != nil {
+		return err
+	}
+
+	if _, err := stdout.Write(manifests); err != nil {
+		return fmt.Errorf("write manifests: %w", err)
+	}
+
+	return nil
+}
+
+// buildKustomization applies the patches in dir to the manifests written to
+// it in-process.
+func (i *Instance) buildKustomization(ctx context.Context, dir string) (_ []byte, err error) {
+	_, span := i.startSpan(ctx, "kustomize build")
+	defer func() { tracing.End(span, err) }()
+
+	start := time.Now()
+	manifests, err := kustomize.Build(dir)
+	if err != nil {
+		return nil, fmt.Errorf("build kustomization: %w", err)
+	}
+	i.log().Debug("applied patches in-process", "dir", dir, "duration", time.Since(start))
+
+	return manifests, nil
+}
+
+type templateOptions struct {
+	install     *action.Install
+	values      *values.Options
+	settings    *cli.EnvSettings
+	args        []string
+	kubeVersion string
+	apiVersions []string
+	skipTests   bool
+}
+
+func parseTemplateArgs(args []string) (*templateOptions, error) {
+	opts := &templateOptions{
+		install:  action.NewInstall(new(action.Configuration)),
+		values:   new(values.Options),
+		settings: cli.New(),
+	}
+
+	var namespace string
+
+	f := pflag.NewFlagSet("template", pflag.ContinueOnError)
+	f.SetOutput(new(bytes.Buffer))
+	f.StringVarP(&namespace, "namespace", "n", "", "")
+	f.StringArrayVar(&opts.values.Values, "set", nil, "")
+	f.StringArrayVar(&opts.values.StringValues, "set-string", nil, "")
+	f.StringArrayVar(&opts.values.FileValues, "set-file", nil, "")
+	f.StringArrayVar(&opts.values.JSONValues, "set-json", nil, "")
+	f.StringArrayVar(&opts.values.LiteralValues, "set-literal", nil, "")
+	f.StringVar(&opts.install.Version, "version", "", "")
+	f.StringVar(&opts.install.RepoURL, "repo", "", "")
+	f.BoolVar(&opts.install.Devel, "devel", false, "")
+	f.BoolVarP(&opts.install.GenerateName, "generate-name", "g", false, "")
+	f.StringVar(&opts.install.NameTemplate, "name-template", "", "")
+	f.BoolVar(&opts.install.IncludeCRDs, "include-crds", false, "")
+	f.BoolVar(&opts.install.SkipCRDs, "skip-crds", false, "")
+	f.BoolVar(&opts.install.DisableHooks, "no-hooks", false, "")
+	f.BoolVar(&opts.install.IsUpgrade, "is-upgrade", false, "")
+	f.BoolVar(&opts.skipTests, "skip-tests", false, "")
+	f.StringVar(&opts.kubeVersion, "kube-version", "", "")
+	f.StringSliceVarP(&opts.apiVersions, "api-versions", "a", nil, "")
+
+	if err := f.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if len(f.Args()) == 0 {
+		return nil, errors.New("no chart provided")
+	}
+
+	if namespace != "" {
+		opts.settings.SetNamespace(namespace)
+	}
+	opts.args = f.Args()
+
+	return opts, nil
+}
+
+func (o *templateOptions) run(ctx context.Context) (*release.Release, error) {
+	client := o.install
+	client.DryRun = true
+	client.DryRunOption = "true"
+	client.ClientOnly = true
+	client.Replace = true
+	client.ReleaseName = DefaultReleaseName
+	client.Namespace = o.settings.Namespace()
+	client.APIVersions = chartutil.VersionSet(o.apiVersions)
+
+	if client.Version == "" && client.Devel {
+		client.Version = ">0.0.0-0"
+	}
+
+	if o.kubeVersion != "" {
+		kubeVersion, err := chartutil.ParseKubeVersion(o.kubeVersion)
+		if err != nil {
+			return nil, fmt.Errorf("parse kube version: %w", err)
+		}
+		client.KubeVersion = kubeVersion
+	}
+
+	registryClient, err := registry.NewClient(
+		registry.ClientOptEnableCache(true),
+		registry.ClientOptCredentialsFile(o.settings.RegistryConfig),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("create registry client: %w", err)
+	}
+	client.SetRegistryClient(registryClient)
+
+	name, ref, err := client.NameAndChart(o.args)
+	if err != nil {
+		return nil, err
+	}
+	client.ReleaseName = name
+
+	path, err := client.LocateChart(ref, o.settings)
+	if err != nil {
+		return nil, fmt.Errorf("locate chart: %w", err)
+	}
+
+	chart, err := loader.Load(path)
+	if err != nil {
+		return nil, fmt.Errorf("load chart: %w", err)
+	}
+
+	if deps := chart.Metadata.Dependencies; deps != nil {
+		if err := action.CheckDependencies(chart, deps); err != nil {
+			return nil, fmt.Errorf("check chart dependencies: %w", err)
+		}
+	}
+
+	vals, err := o.values.MergeValues(getter.All(o.settings))
+	if err != nil {
+		return nil, fmt.Errorf("merge values: %w", err)
+	}
+
+	return client.RunWithContext(ctx, chart, vals)
+}
@@ -0,0 +1,354 @@
+package konduit_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+	"github.com/jace-ys/konduit/pkg/konduit/mocks"
+)
+
+func TestInstance_Render(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name               string
+		instance           *konduit.Instance
+		setupMockEvaluator func(*mocks.MockEvaluator)
+		wantYAML           string
+		wantErr            string
+	}{
+		{
+			name: "renders chart with default values",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release", "testdata/chart"},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			wantYAML: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-release
+  namespace: default
+data:
+  greeting: hello
+`,
+		},
+		{
+			name: "renders chart with namespace and set values",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release", "testdata/chart", "-n", "my-namespace", "--set", "greeting=hey"},
+				Values:   []string{"testdata/values.yaml"},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			wantYAML: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-release
+  namespace: my-namespace
+data:
+  greeting: hey
+`,
+		},
+		{
+			name: "renders chart with evaluated values before static values",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"template", "testdata/chart"},
+				ValuesToEvaluate: []string{"values.cue"},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {
+				m.EXPECT().Evaluate(mock.Anything, []string{"values.cue"}).Return(mustYAMLResult("greeting: evaluated\n"), nil)
+			},
+			wantYAML: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: release-name
+  namespace: default
+data:
+  greeting: evaluated
+`,
+		},
+		{
+			name: "applies patches in-process",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release", "testdata/chart"},
+				Patches:  []string{"testdata/patches.yaml"},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			wantYAML: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: patched-my-release
+  namespace: default
+data:
+  greeting: hello
+`,
+		},
+		{
+			name: "pipes manifests through post-renderers before and after patches",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release", "testdata/chart"},
+				Patches:  []string{"testdata/patches.yaml"},
+				PostRenderersBefore: []konduit.PostRenderer{
+					{Command: "sed", Args: []string{"s/name: my-release/name: chained/"}},
+				},
+				PostRenderersAfter: []konduit.PostRenderer{
+					{Command: "sed", Args: []string{"s/name: patched-/name: after-/"}},
+				},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			wantYAML: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: after-chained
+  namespace: default
+data:
+  greeting: hello
+`,
+		},
+		{
+			name: "pipes manifests through post-renderers after the helm post-renderer",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"template", "my-release", "testdata/chart"},
+				PostRenderer:     "sed",
+				PostRendererArgs: []string{"s/hello/hey/"},
+				PostRenderersAfter: []konduit.PostRenderer{
+					{Command: "sed", Args: []string{"s/hey/hey there/"}},
+				},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			wantYAML: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: my-release
+  namespace: default
+data:
+  greeting: hey there
+`,
+		},
+		{
+			name: "returns error for failing post-renderer",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release", "testdata/chart"},
+				Patches:  []string{"testdata/patches.yaml"},
+				PostRenderersBefore: []konduit.PostRenderer{
+					{Command: "false"},
+				},
+				PostRenderersAfter: []konduit.PostRenderer{
+					{Command: "cat"},
+				},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			wantErr:            "run post-renderer false",
+		},
+		{
+			name: "returns error for unsupported command",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"install", "my-release", "testdata/chart"},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			wantErr:            "in-process rendering only supports the template command",
+		},
+		{
+			name: "returns error for unsupported flag",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release", "testdata/chart", "--atomic"},
+			},
+			setupMockEvaluator: func(m *mocks.MockEvaluator) {},
+			wantErr:            "unknown flag: --atomic",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			konduit.WithWorkDir(t.TempDir()).Apply(tt.instance)
+			konduit.WithRunner(konduit.NewOSRunner()).Apply(tt.instance)
+
+			eval := mocks.NewMockEvaluator(t)
+			tt.setupMockEvaluator(eval)
+			konduit.WithEvaluator(eval).Apply(tt.instance)
+
+			actual, err := tt.instance.Render(t.Context())
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				require.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.YAMLEq(t, tt.wantYAML, string(actual))
+		})
+	}
+}
+
+func TestInstance_Render_WithKeepWorkDir(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		keep      bool
+		wantFiles []string
+	}{
+		{
+			name:      "keeps intermediate manifests and describes them",
+			keep:      true,
+			wantFiles: []string{"artifacts.json", "evaluated.yaml", "kustomization.yaml", "kustomized.yaml", "manifests.yaml"},
+		},
+		{
+			name:      "removes intermediate manifests by default",
+			wantFiles: []string{"artifacts.json", "evaluated.yaml", "kustomization.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			instance := &konduit.Instance{
+				HelmArgs:         []string{"template", "my-release", "testdata/chart"},
+				ValuesToEvaluate: []string{"values.cue"},
+				Patches:          []string{"testdata/patches.yaml"},
+			}
+
+			eval := mocks.NewMockEvaluator(t)
+			eval.EXPECT().Evaluate(mock.Anything, []string{"values.cue"}).Return(mustYAMLResult("greeting: evaluated\n"), nil)
+
+			dir := t.TempDir()
+			konduit.WithEvaluator(eval).Apply(instance)
+			konduit.WithWorkDir(dir).Apply(instance)
+			konduit.WithKeepWorkDir(tt.keep).Apply(instance)
+
+			manifests, err := instance.Render(t.Context())
+			require.NoError(t, err)
+
+			entries, err := os.ReadDir(dir)
+			require.NoError(t, err)
+
+			files := make([]string, len(entries))
+			for idx, entry := range entries {
+				files[idx] = entry.Name()
+			}
+			assert.Equal(t, tt.wantFiles, files)
+
+			if !tt.keep {
+				return
+			}
+
+			output, err := os.ReadFile(filepath.Join(dir, "kustomized.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, string(output), string(manifests))
+
+			data, err := os.ReadFile(filepath.Join(dir, konduit.ArtifactsFile))
+			require.NoError(t, err)
+
+			var artifacts konduit.Artifacts
+			require.NoError(t, json.Unmarshal(data, &artifacts))
+			assert.Equal(t, "helm", artifacts.Engine)
+
+			paths := make([]string, len(artifacts.Files))
+			for idx, file := range artifacts.Files {
+				paths[idx] = file.Path
+			}
+			assert.Equal(t, []string{"evaluated.yaml", "kustomization.yaml", "manifests.yaml", "kustomized.yaml"}, paths)
+		})
+	}
+}
+
+func TestInstance_Render_ReusesWorkDir(t *testing.T) {
+	t.Parallel()
+
+	newInstance := func(t *testing.T, dir string, patches []string) *konduit.Instance {
+		t.Helper()
+
+		instance := &konduit.Instance{
+			HelmArgs: []string{"template", "my-release", "testdata/chart"},
+			Patches:  patches,
+		}
+		konduit.WithEvaluator(konduit.NewNoopEvaluator()).Apply(instance)
+		konduit.WithWorkDir(dir).Apply(instance)
+		konduit.WithKeepWorkDir(true).Apply(instance)
+
+		return instance
+	}
+
+	t.Run("replaces files of a previous invocation", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+
+		_, err := newInstance(t, dir, []string{"testdata/patches.yaml"}).Render(t.Context())
+		require.NoError(t, err)
+
+		manifests, err := newInstance(t, dir, nil).Render(t.Context())
+		require.NoError(t, err)
+		assert.Contains(t, string(manifests), "name: my-release")
+
+		assert.NoFileExists(t, filepath.Join(dir, "kustomization.yaml"))
+		assert.NoFileExists(t, filepath.Join(dir, "manifests.yaml"))
+		assert.FileExists(t, filepath.Join(dir, konduit.ArtifactsFile))
+	})
+
+	t.Run("keeps files it didn't write", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("docs\n"), 0o644))
+
+		_, err := newInstance(t, dir, []string{"testdata/patches.yaml"}).Render(t.Context())
+		require.NoError(t, err)
+
+		_, err = newInstance(t, dir, nil).Render(t.Context())
+		require.NoError(t, err)
+
+		assert.FileExists(t, filepath.Join(dir, "README.md"))
+		assert.NoFileExists(t, filepath.Join(dir, ".konduit.lock"))
+
+		info, err := os.Stat(filepath.Join(dir, konduit.ArtifactsFile))
+		require.NoError(t, err)
+		if runtime.GOOS != "windows" {
+			assert.Equal(t, os.FileMode(0o644), info.Mode().Perm())
+		}
+	})
+
+	t.Run("fails instead of overwriting files it didn't write", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "kustomization.yaml"), []byte("resources: []\n"), 0o644))
+
+		_, err := newInstance(t, dir, []string{"testdata/patches.yaml"}).Render(t.Context())
+		require.ErrorContains(t, err, "kustomization.yaml wasn't written by Konduit")
+
+		data, err := os.ReadFile(filepath.Join(dir, "kustomization.yaml"))
+		require.NoError(t, err)
+		assert.Equal(t, "resources: []\n", string(data))
+	})
+
+	t.Run("succeeds for concurrent invocations", func(t *testing.T) {
+		t.Parallel()
+
+		dir := t.TempDir()
+
+		var wg sync.WaitGroup
+		errs := make([]error, 5)
+		for idx := range errs {
+			wg.Go(func() {
+				_, errs[idx] = newInstance(t, dir, []string{"testdata/patches.yaml"}).Render(t.Context())
+			})
+		}
+		wg.Wait()
+
+		for _, err := range errs {
+			assert.NoError(t, err)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package konduit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"cuelang.org/go/cue/cuecontext"
+	"cuelang.org/go/encoding/yaml"
+)
+
+type Encoding string
+
+const EncodingYAML Encoding = "yaml"
+
+type FileMetadata struct {
+	Path    string `json:"path"`
+	Package string `json:"package,omitempty"`
+}
+
+// Result is the outcome of evaluating a set of files. Data holds the decoded
+// result, and Raw holds the same result in its original encoding.
+type Result struct {
+	Data     map[string]any `json:"data"`
+	Encoding Encoding       `json:"encoding"`
+	Raw      []byte         `json:"-"`
+	Files    []FileMetadata `json:"files,omitempty"`
+}
+
+// NewYAMLResult decodes a YAML document into a Result. Numbers are decoded the
+// same way as CUE values, as int64 or float64.
+func NewYAMLResult(raw []byte, files []FileMetadata) (*Result, error) {
+	data := make(map[string]any)
+
+	if len(raw) > 0 {
+		f, err := yaml.Extract("", raw)
+		if err != nil {
+			return nil, fmt.Errorf("extract YAML: %w", err)
+		}
+
+		if err := cuecontext.New().BuildFile(f).Decode(&data); err != nil {
+			return nil, fmt.Errorf("decode YAML: %w", err)
+		}
+	}
+
+	return &Result{
+		Data:     data,
+		Encoding: EncodingYAML,
+		Raw:      raw,
+		Files:    files,
+	}, nil
+}
+
+// Decode decodes the result into v, which can be any type that Data can be
+// converted into through JSON.
+func (r *Result) Decode(v any) error {
+	data, err := r.JSON()
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("decode result: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Result) JSON() ([]byte, error) {
+	data, err := json.Marshal(r.Data)
+	if err != nil {
+		return nil, fmt.Errorf("encode result: %w", err)
+	}
+	return data, nil
+}
+
+type Evaluation struct {
+	Files  []string
+	Result *Result
+}
+
+// encodedEvaluation is how an Evaluation is encoded, such as by --show. Result
+// holds the result in its original encoding, as it did before results were
+// structured, and StructuredResult holds the structured result alongside it.
+type encodedEvaluation struct {
+	Files            []string `json:"files,omitempty"`
+	Result           string   `json:"result,omitempty"`
+	StructuredResult *Result  `json:"structuredResult,omitempty"`
+}
+
+func (e Evaluation) encoded() encodedEvaluation {
+	return encodedEvaluation{Files: e.Files, Result: string(e.Raw()), StructuredResult: e.Result}
+}
+
+func (e Evaluation) MarshalJSON() ([]byte, error) {
+	return json.Marshal(e.encoded())
+}
+
+func (e Evaluation) MarshalYAML() (any, error) {
+	return e.encoded(), nil
+}
+
+func (e *Evaluation) UnmarshalJSON(data []byte) error {
+	var encoded encodedEvaluation
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+
+	e.Files = encoded.Files
+	e.Result = encoded.StructuredResult
+
+	switch {
+	case e.Result != nil:
+		e.Result.Raw = []byte(encoded.Result)
+	case encoded.Result != "":
+		result, err := NewYAMLResult([]byte(encoded.Result), nil)
+		if err != nil {
+			return err
+		}
+		e.Result = result
+	}
+
+	return nil
+}
+
+// Raw returns the result in its original encoding, or nil if there is no
+// result.
+func (e *Evaluation) Raw() []byte {
+	if e == nil || e.Result == nil {
+		return nil
+	}
+	return e.Result.Raw
+}
+
+func (e *Evaluation) Decode(v any) error {
+	if e == nil || e.Result == nil {
+		return errors.New("no evaluation result")
+	}
+	return e.Result.Decode(v)
+}
+
+// EvaluationError reports that evaluating values or patches failed, as opposed
+// to a failure to run the engine.
+type EvaluationError struct {
+	Err error
+}
+
+func (e *EvaluationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *EvaluationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,150 @@
+package konduit_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+func mustYAMLResult(raw string) *konduit.Result {
+	result, err := konduit.NewYAMLResult([]byte(raw), nil)
+	if err != nil {
+		panic(err)
+	}
+	return result
+}
+
+func TestNewYAMLResult(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		raw      string
+		wantData map[string]any
+		wantErr  string
+	}{
+		{
+			name: "decodes values like CUE",
+			raw:  "replicas: 3\nratio: 0.5\nname: app\nlabels:\n  - a\n",
+			wantData: map[string]any{
+				"replicas": int64(3),
+				"ratio":    0.5,
+				"name":     "app",
+				"labels":   []any{"a"},
+			},
+		},
+		{
+			name:     "decodes empty document",
+			raw:      "",
+			wantData: map[string]any{},
+		},
+		{
+			name:    "returns error when document is not a map",
+			raw:     "- a\n- b\n",
+			wantErr: "decode YAML",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			result, err := konduit.NewYAMLResult([]byte(tt.raw), nil)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantData, result.Data)
+			assert.Equal(t, konduit.EncodingYAML, result.Encoding)
+			assert.Equal(t, tt.raw, string(result.Raw))
+		})
+	}
+}
+
+func TestEvaluation_Decode(t *testing.T) {
+	t.Parallel()
+
+	eval := &konduit.Evaluation{
+		Result: mustYAMLResult("image:\n  repository: nginx\n  tag: latest\nreplicas: 2\n"),
+	}
+
+	var values struct {
+		Image struct {
+			Repository string `json:"repository"`
+			Tag        string `json:"tag"`
+		} `json:"image"`
+		Replicas int `json:"replicas"`
+	}
+	require.NoError(t, eval.Decode(&values))
+
+	assert.Equal(t, "nginx", values.Image.Repository)
+	assert.Equal(t, "latest", values.Image.Tag)
+	assert.Equal(t, 2, values.Replicas)
+
+	var empty *konduit.Evaluation
+	assert.Nil(t, empty.Raw())
+	assert.ErrorContains(t, empty.Decode(&values), "no evaluation result")
+}
+
+func TestEvaluation_JSON(t *testing.T) {
+	t.Parallel()
+
+	result, err := konduit.NewYAMLResult([]byte("replicas: 2\n"), []konduit.FileMetadata{{Path: "values.cue", Package: "values"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		evaluation *konduit.Evaluation
+		want       string
+	}{
+		{
+			name:       "encodes files without a result",
+			evaluation: &konduit.Evaluation{Files: []string{"values.cue"}},
+			want:       `{"files": ["values.cue"]}`,
+		},
+		{
+			name:       "encodes the result as YAML and in structured form",
+			evaluation: &konduit.Evaluation{Files: []string{"values.cue"}, Result: result},
+			want: `{
+				"files": ["values.cue"],
+				"result": "replicas: 2\n",
+				"structuredResult": {
+					"data": {"replicas": 2},
+					"encoding": "yaml",
+					"files": [{"path": "values.cue", "package": "values"}]
+				}
+			}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			data, err := json.Marshal(tt.evaluation)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.want, string(data))
+
+			var decoded konduit.Evaluation
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, tt.evaluation.Files, decoded.Files)
+			assert.Equal(t, tt.evaluation.Raw(), decoded.Raw())
+		})
+	}
+
+	t.Run("decodes a YAML result without a structured result", func(t *testing.T) {
+		t.Parallel()
+
+		var decoded konduit.Evaluation
+		require.NoError(t, json.Unmarshal([]byte(`{"files": ["values.cue"], "result": "replicas: 2\n"}`), &decoded))
+		assert.Equal(t, "replicas: 2\n", string(decoded.Raw()))
+		assert.Equal(t, map[string]any{"replicas": int64(2)}, decoded.Result.Data)
+	})
+}
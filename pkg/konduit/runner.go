@@ -0,0 +1,47 @@
+package konduit
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/jace-ys/konduit/internal/exec"
+)
+
+// RunOption configures how a Runner runs a command.
+type RunOption = exec.RunOption
+
+// NewOSRunner returns a Runner that runs commands as child processes, applying
+// opts to every command. Pass it to WithRunner to control the environment of
+// Helm and post-renderers.
+func NewOSRunner(opts ...RunOption) Runner {
+	return exec.NewOSRunner(opts...)
+}
+
+// RunWithEnv sets environment variables for child processes, in KEY=VALUE
+// form.
+func RunWithEnv(env ...string) RunOption {
+	return exec.WithEnv(env...)
+}
+
+// RunWithoutEnv removes inherited environment variables matching the given
+// patterns, which may contain * wildcards.
+func RunWithoutEnv(patterns ...string) RunOption {
+	return exec.WithoutEnv(patterns...)
+}
+
+// RunWithEnvAllowlist restricts inherited environment variables to those
+// matching the given patterns, which may contain * wildcards.
+func RunWithEnvAllowlist(patterns ...string) RunOption {
+	return exec.WithEnvAllowlist(patterns...)
+}
+
+// RunWithGracePeriod sets how long to wait for a child process to exit after
+// forwarding an interrupt, before killing it.
+func RunWithGracePeriod(d time.Duration) RunOption {
+	return exec.WithGracePeriod(d)
+}
+
+// RunWithLogger logs each command and how long it took at debug level.
+func RunWithLogger(logger *slog.Logger) RunOption {
+	return exec.WithLogger(logger)
+}
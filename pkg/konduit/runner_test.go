@@ -0,0 +1,73 @@
+package konduit_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+func TestOSRunner_Env(t *testing.T) {
+	t.Setenv("KONDUIT_TEST_KEEP", "keep")
+	t.Setenv("KONDUIT_TEST_SECRET", "secret")
+
+	tests := []struct {
+		name    string
+		opts    []konduit.RunOption
+		want    []string
+		wantNot []string
+	}{
+		{
+			name: "inherits environment by default",
+			want: []string{"KONDUIT_TEST_KEEP=keep", "KONDUIT_TEST_SECRET=secret"},
+		},
+		{
+			name: "sets and overrides variables",
+			opts: []konduit.RunOption{
+				konduit.RunWithEnv("KONDUIT_TEST_KEEP=override", "KONDUIT_TEST_NEW=new"),
+			},
+			want:    []string{"KONDUIT_TEST_KEEP=override", "KONDUIT_TEST_NEW=new"},
+			wantNot: []string{"KONDUIT_TEST_KEEP=keep"},
+		},
+		{
+			name: "unsets variables matching patterns",
+			opts: []konduit.RunOption{
+				konduit.RunWithoutEnv("KONDUIT_TEST_SEC*"),
+			},
+			want:    []string{"KONDUIT_TEST_KEEP=keep"},
+			wantNot: []string{"KONDUIT_TEST_SECRET=secret"},
+		},
+		{
+			name: "only inherits allowlisted variables",
+			opts: []konduit.RunOption{
+				konduit.RunWithEnvAllowlist("PATH", "KONDUIT_TEST_KEEP"),
+				konduit.RunWithEnv("KONDUIT_TEST_NEW=new"),
+			},
+			want:    []string{"KONDUIT_TEST_KEEP=keep", "KONDUIT_TEST_NEW=new"},
+			wantNot: []string{"KONDUIT_TEST_SECRET=secret"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			runner := konduit.NewOSRunner(tt.opts...)
+
+			err := runner.Run(t.Context(), "env", nil, exec.WithStdout(&stdout))
+			require.NoError(t, err)
+
+			env := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+			for _, kv := range tt.want {
+				assert.Contains(t, env, kv)
+			}
+			for _, kv := range tt.wantNot {
+				assert.NotContains(t, env, kv)
+			}
+		})
+	}
+}
@@ -0,0 +1,220 @@
+package konduit
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+
+	"github.com/jace-ys/konduit/internal/kustomize"
+)
+
+// PostRendererScript stands in for the Konduit post-renderer in a script
+// returned by Script.
+const PostRendererScript = "post-renderer.sh"
+
+const scriptDelimiter = "KONDUIT_EOF"
+
+// Script returns a shell script that reproduces the invocation without
+// Konduit. It writes the evaluated values and the Kustomization of the patches
+// to a temporary directory, and runs Helm or Timoni with the same arguments as
+// Execute would. Patches are applied by running kustomize, which must be
+// installed. Helm 4 only runs post-renderers that are plugins, so its output is
+// piped through kustomize instead, which only works with helm template. Paths
+// to the chart and to static values files are kept as they are, so the script
+// must be run from the same directory.
+func (i *Instance) Script(ctx context.Context) ([]byte, error) {
+	dir := i.dir
+	i.dir = WorkDirPlaceholder
+	defer func() { i.dir = dir }()
+
+	inv, err := i.Construct(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("construct invocation: %w", err)
+	}
+
+	var script bytes.Buffer
+	fmt.Fprintln(&script, "#!/bin/sh")
+	fmt.Fprint(&script, scriptOptions)
+	fmt.Fprintln(&script)
+	fmt.Fprintf(&script, "%s=\"$(mktemp -d)\"\n", workDirVariable)
+	fmt.Fprintf(&script, "export %s\n", workDirVariable)
+	fmt.Fprintf(&script, "trap 'rm -rf \"$%s\"' EXIT\n", workDirVariable)
+
+	if raw := inv.EvaluatedValues.Raw(); len(raw) > 0 {
+		writeScriptFile(&script, ValuesFile, raw)
+	}
+
+	_, helm := i.Engine().(*HelmEngine)
+
+	args := inv.Args
+	if i.hasPatches() {
+		kustomization, err := inv.kustomization()
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := yaml.Marshal(kustomization)
+		if err != nil {
+			return nil, fmt.Errorf("encode kustomization: %w", err)
+		}
+		writeScriptFile(&script, kustomize.KustomizationFile, data)
+	}
+
+	// Timoni doesn't support post-renderers, and Helm 4 only supports plugins,
+	// so their output is piped through the post-renderers and patched after.
+	pipe := i.usesKonduitPostRenderer() && (!helm || i.HelmVersion >= HelmVersion4)
+
+	if helm && i.usesKonduitPostRenderer() {
+		if pipe {
+			args, err = i.scriptHelm4Args(args)
+			if err != nil {
+				return nil, err
+			}
+		} else {
+			args = i.scriptPostRenderer(&script, args)
+		}
+	}
+
+	fmt.Fprintln(&script)
+	command := shellCommand(inv.Command, args)
+
+	if pipe {
+		fmt.Fprintln(&script, i.scriptPipeline(command))
+	} else {
+		fmt.Fprintln(&script, command)
+	}
+
+	return script.Bytes(), nil
+}
+
+// scriptHelm4Args returns the args without the Konduit post-renderer plugin,
+// so that the output of helm template can be piped through the post-renderers
+// and kustomize instead. Other commands install the manifests themselves, so
+// they can't be reproduced without the plugin.
+func (i *Instance) scriptHelm4Args(args []string) ([]string, error) {
+	if len(i.HelmArgs) == 0 || i.HelmArgs[0] != "template" {
+		return nil, fmt.Errorf("scripts only support patches and post-renderers with helm template on helm 4, as other commands need the %s plugin", PostRendererPluginName)
+	}
+
+	if i.PostRenderer != "" {
+		return nil, fmt.Errorf("scripts don't support the post-renderer plugin %q on helm 4", i.PostRenderer)
+	}
+
+	if idx := slices.Index(args, "--post-renderer"); idx >= 0 {
+		args = args[:idx]
+	}
+
+	return args, nil
+}
+
+// scriptPostRenderer writes a post-renderer that applies the patches with
+// kustomize and runs the chained post-renderers, and returns the args with it
+// in place of the Konduit binary. The arguments meant for the Konduit
+// post-renderer are passed to it, but ignored.
+func (i *Instance) scriptPostRenderer(script *bytes.Buffer, args []string) []string {
+	postRenderer := fmt.Sprintf("#!/bin/sh\n%s%s\n", scriptOptions, i.scriptPipeline(""))
+	writeScriptFile(script, PostRendererScript, []byte(postRenderer))
+	fmt.Fprintf(script, "chmod +x %s\n", shellQuote(filepath.Join(WorkDirPlaceholder, PostRendererScript)))
+
+	args = slices.Clone(args)
+	if idx := slices.Index(args, "--post-renderer"); idx >= 0 && idx+1 < len(args) {
+		args[idx+1] = filepath.Join(WorkDirPlaceholder, PostRendererScript)
+	}
+
+	return args
+}
+
+// scriptPipeline returns the commands that pipe the manifests from input, or
+// from stdin if input is empty, through the post-renderers before patches,
+// kustomize if there are patches, and the post-renderers after them.
+func (i *Instance) scriptPipeline(input string) string {
+	var before, after []string
+	if input != "" {
+		before = append(before, input)
+	}
+	for _, postRenderer := range i.PostRenderersBefore {
+		before = append(before, shellCommand(postRenderer.Command, postRenderer.Args))
+	}
+	for _, postRenderer := range i.postRenderersAfter() {
+		after = append(after, shellCommand(postRenderer.Command, postRenderer.Args))
+	}
+
+	if !i.hasPatches() {
+		return strings.Join(append(before, after...), " | ")
+	}
+
+	if len(before) == 0 {
+		before = append(before, "cat")
+	}
+
+	manifests := shellQuote(filepath.Join(WorkDirPlaceholder, kustomize.ManifestsFile))
+	build := append([]string{"kustomize build " + shellQuote(WorkDirPlaceholder)}, after...)
+
+	return strings.Join(before, " | ") + " > " + manifests + "\n" + strings.Join(build, " | ")
+}
+
+// scriptOptions make a script stop at the first failure, including that of a
+// command in a pipeline where the shell supports pipefail, so that a failing
+// post-renderer isn't hidden by the commands after it.
+const scriptOptions = "set -eu\n(set -o pipefail) 2>/dev/null && set -o pipefail\n"
+
+// workDirVariable is the shell variable that WorkDirPlaceholder expands.
+var workDirVariable = strings.TrimPrefix(WorkDirPlaceholder, "$")
+
+func writeScriptFile(script *bytes.Buffer, name string, data []byte) {
+	delimiter := scriptDelimiter
+	for bytes.Contains(data, []byte(delimiter)) {
+		delimiter += "_"
+	}
+
+	fmt.Fprintf(script, "\ncat > %s <<'%s'\n", shellQuote(filepath.Join(WorkDirPlaceholder, name)), delimiter)
+	script.Write(data)
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		script.WriteByte('\n')
+	}
+	fmt.Fprintln(script, delimiter)
+}
+
+func shellCommand(command string, args []string) string {
+	words := make([]string, 0, len(args)+1)
+	for _, word := range append([]string{command}, args...) {
+		words = append(words, shellQuote(word))
+	}
+	return strings.Join(words, " ")
+}
+
+// shellSafe matches words that don't need to be quoted.
+var shellSafe = regexp.MustCompile(`^[\w@%+=:,./-]*$`)
+
+// shellQuote quotes s as a single shell word, expanding WorkDirPlaceholder to
+// the work dir of the script.
+func shellQuote(s string) string {
+	if s == "" {
+		return "''"
+	}
+
+	parts := strings.Split(s, WorkDirPlaceholder)
+	for idx, part := range parts {
+		if part != "" {
+			parts[idx] = quoteWord(part)
+		}
+	}
+	return strings.Join(parts, `"$`+workDirVariable+`"`)
+}
+
+// quoteWord quotes s as a single shell word, if it needs to be.
+func quoteWord(s string) string {
+	if s == "" {
+		return "''"
+	}
+	if shellSafe.MatchString(s) {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
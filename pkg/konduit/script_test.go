@@ -0,0 +1,246 @@
+package konduit_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+	"github.com/jace-ys/konduit/pkg/konduit/mocks"
+)
+
+func TestInstance_Script(t *testing.T) {
+	t.Parallel()
+
+	const header = `#!/bin/sh
+set -eu
+(set -o pipefail) 2>/dev/null && set -o pipefail
+
+KONDUIT_WORK_DIR="$(mktemp -d)"
+export KONDUIT_WORK_DIR
+trap 'rm -rf "$KONDUIT_WORK_DIR"' EXIT
+`
+
+	const kustomization = `
+cat > "$KONDUIT_WORK_DIR"/kustomization.yaml <<'KONDUIT_EOF'
+kind: Kustomization
+apiVersion: kustomize.config.k8s.io/v1beta1
+namePrefix: patched-
+resources:
+- manifests.yaml
+KONDUIT_EOF
+`
+
+	tests := []struct {
+		name     string
+		instance *konduit.Instance
+		opts     []konduit.Option
+		want     string
+	}{
+		{
+			name: "writes evaluated values and runs helm",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"template", "my-release", "./chart", "--set", "greeting=it's me"},
+				ValuesToEvaluate: []string{"values.cue"},
+				Values:           []string{"values.yaml"},
+			},
+			want: header + `
+cat > "$KONDUIT_WORK_DIR"/evaluated.yaml <<'KONDUIT_EOF'
+greeting: evaluated
+KONDUIT_EOF
+
+helm template my-release ./chart --set 'greeting=it'\''s me' --values "$KONDUIT_WORK_DIR"/evaluated.yaml --values values.yaml
+`,
+		},
+		{
+			name: "applies patches with a post-renderer script",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"template", "my-release", "./chart"},
+				Patches:          []string{"testdata/patches.yaml"},
+				PostRenderer:     "my-renderer",
+				PostRendererArgs: []string{"--flag"},
+			},
+			want: header + kustomization + `
+cat > "$KONDUIT_WORK_DIR"/post-renderer.sh <<'KONDUIT_EOF'
+#!/bin/sh
+set -eu
+(set -o pipefail) 2>/dev/null && set -o pipefail
+cat > "$KONDUIT_WORK_DIR"/manifests.yaml
+kustomize build "$KONDUIT_WORK_DIR" | my-renderer --flag
+KONDUIT_EOF
+chmod +x "$KONDUIT_WORK_DIR"/post-renderer.sh
+
+helm template my-release ./chart --post-renderer "$KONDUIT_WORK_DIR"/post-renderer.sh --post-renderer-args kustomize --post-renderer-args --dir --post-renderer-args "$KONDUIT_WORK_DIR" --post-renderer-args --post-renderer --post-renderer-args my-renderer --post-renderer-args --post-renderer-args --post-renderer-args --flag
+`,
+		},
+		{
+			name: "chains post-renderers around patches in the post-renderer script",
+			instance: &konduit.Instance{
+				HelmArgs:     []string{"template", "my-release", "./chart"},
+				Patches:      []string{"testdata/patches.yaml"},
+				PostRenderer: "my-renderer",
+				PostRenderersBefore: []konduit.PostRenderer{
+					{Command: "helm-secrets", Args: []string{"post-render"}},
+				},
+				PostRenderersAfter: []konduit.PostRenderer{
+					{Command: "sed", Args: []string{"s/a b/c/"}},
+				},
+			},
+			want: header + kustomization + `
+cat > "$KONDUIT_WORK_DIR"/post-renderer.sh <<'KONDUIT_EOF'
+#!/bin/sh
+set -eu
+(set -o pipefail) 2>/dev/null && set -o pipefail
+helm-secrets post-render > "$KONDUIT_WORK_DIR"/manifests.yaml
+kustomize build "$KONDUIT_WORK_DIR" | my-renderer | sed 's/a b/c/'
+KONDUIT_EOF
+chmod +x "$KONDUIT_WORK_DIR"/post-renderer.sh
+
+helm template my-release ./chart --post-renderer "$KONDUIT_WORK_DIR"/post-renderer.sh --post-renderer-args kustomize --post-renderer-args --dir --post-renderer-args "$KONDUIT_WORK_DIR" --post-renderer-args --before --post-renderer-args 'helm-secrets post-render' --post-renderer-args --post-renderer --post-renderer-args my-renderer --post-renderer-args --after --post-renderer-args 'sed '\''s/a b/c/'\'''
+`,
+		},
+		{
+			name: "chains post-renderers without patches in the post-renderer script",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"template", "my-release", "./chart"},
+				PostRenderersAfter: []konduit.PostRenderer{
+					{Command: "pin-digests"},
+					{Command: "sed", Args: []string{"s/a b/c/"}},
+				},
+			},
+			want: header + `
+cat > "$KONDUIT_WORK_DIR"/post-renderer.sh <<'KONDUIT_EOF'
+#!/bin/sh
+set -eu
+(set -o pipefail) 2>/dev/null && set -o pipefail
+pin-digests | sed 's/a b/c/'
+KONDUIT_EOF
+chmod +x "$KONDUIT_WORK_DIR"/post-renderer.sh
+
+helm template my-release ./chart --post-renderer "$KONDUIT_WORK_DIR"/post-renderer.sh --post-renderer-args kustomize --post-renderer-args --after --post-renderer-args pin-digests --post-renderer-args --after --post-renderer-args 'sed '\''s/a b/c/'\'''
+`,
+		},
+		{
+			name: "pipes the output of helm 4 through post-renderers",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"template", "my-release", "./chart"},
+				HelmVersion:      konduit.HelmVersion4,
+				ValuesToEvaluate: []string{"values.cue"},
+				Patches:          []string{"testdata/patches.yaml"},
+				PostRenderersAfter: []konduit.PostRenderer{
+					{Command: "pin-digests"},
+				},
+			},
+			want: header + `
+cat > "$KONDUIT_WORK_DIR"/evaluated.yaml <<'KONDUIT_EOF'
+greeting: evaluated
+KONDUIT_EOF
+` + kustomization + `
+helm template my-release ./chart --values "$KONDUIT_WORK_DIR"/evaluated.yaml > "$KONDUIT_WORK_DIR"/manifests.yaml
+kustomize build "$KONDUIT_WORK_DIR" | pin-digests
+`,
+		},
+		{
+			name: "pipes the output of timoni through post-renderers",
+			instance: &konduit.Instance{
+				HelmCommand: konduit.DefaultTimoniCommand,
+				HelmArgs:    []string{"build", "my-app", "./module"},
+				Patches:     []string{"testdata/patches.yaml"},
+				PostRenderersBefore: []konduit.PostRenderer{
+					{Command: "helm-secrets", Args: []string{"post-render"}},
+				},
+				PostRenderersAfter: []konduit.PostRenderer{
+					{Command: "pin-digests"},
+				},
+			},
+			opts: []konduit.Option{konduit.WithEngine(konduit.NewTimoniEngine())},
+			want: header + kustomization + `
+timoni build my-app ./module | helm-secrets post-render > "$KONDUIT_WORK_DIR"/manifests.yaml
+kustomize build "$KONDUIT_WORK_DIR" | pin-digests
+`,
+		},
+		{
+			name: "applies patches to the output of timoni",
+			instance: &konduit.Instance{
+				HelmCommand: konduit.DefaultTimoniCommand,
+				HelmArgs:    []string{"build", "my-app", "./module"},
+				Patches:     []string{"testdata/patches.yaml"},
+			},
+			opts: []konduit.Option{konduit.WithEngine(konduit.NewTimoniEngine())},
+			want: header + kustomization + `
+timoni build my-app ./module > "$KONDUIT_WORK_DIR"/manifests.yaml
+kustomize build "$KONDUIT_WORK_DIR"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			if tt.instance.HelmCommand == "" {
+				tt.instance.HelmCommand = konduit.DefaultHelmCommand
+			}
+
+			eval := mocks.NewMockEvaluator(t)
+			if len(tt.instance.ValuesToEvaluate) > 0 {
+				eval.EXPECT().Evaluate(mock.Anything, tt.instance.ValuesToEvaluate).Return(mustYAMLResult("greeting: evaluated\n"), nil)
+			}
+			konduit.WithEvaluator(eval).Apply(tt.instance)
+
+			for _, opt := range tt.opts {
+				opt.Apply(tt.instance)
+			}
+
+			script, err := tt.instance.Script(t.Context())
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(script))
+			assert.Empty(t, tt.instance.WorkDir())
+		})
+	}
+}
+
+func TestInstance_ScriptErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		instance *konduit.Instance
+		wantErr  string
+	}{
+		{
+			name: "fails on helm 4 commands other than template",
+			instance: &konduit.Instance{
+				HelmArgs:    []string{"upgrade", "--install", "my-release", "./chart"},
+				HelmVersion: konduit.HelmVersion4,
+				Patches:     []string{"testdata/patches.yaml"},
+			},
+			wantErr: "scripts only support patches and post-renderers with helm template on helm 4, as other commands need the konduit-kustomize plugin",
+		},
+		{
+			name: "fails on helm 4 post-renderer plugins",
+			instance: &konduit.Instance{
+				HelmArgs:     []string{"template", "my-release", "./chart"},
+				HelmVersion:  konduit.HelmVersion4,
+				Patches:      []string{"testdata/patches.yaml"},
+				PostRenderer: "helm-secrets",
+			},
+			wantErr: `scripts don't support the post-renderer plugin "helm-secrets" on helm 4`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tt.instance.HelmCommand = konduit.DefaultHelmCommand
+			konduit.WithEvaluator(mocks.NewMockEvaluator(t)).Apply(tt.instance)
+
+			_, err := tt.instance.Script(t.Context())
+			require.EqualError(t, err, tt.wantErr)
+			assert.Empty(t, tt.instance.WorkDir())
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package konduit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"path/filepath"
+
+	"go.opentelemetry.io/otel/attribute"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/internal/tracing"
+)
+
+const DefaultTimoniCommand = "timoni"
+
+type TimoniEngine struct{}
+
+func NewTimoniEngine() *TimoniEngine {
+	return &TimoniEngine{}
+}
+
+func (e *TimoniEngine) Name() string {
+	return "timoni"
+}
+
+func (e *TimoniEngine) DefaultCommand() string {
+	return DefaultTimoniCommand
+}
+
+func (e *TimoniEngine) Validate(i *Instance) error {
+	if i.PostRenderer != "" {
+		return errors.New("timoni does not support post-renderers")
+	}
+
+	if i.hasPatches() && (len(i.HelmArgs) == 0 || i.HelmArgs[0] != "build") {
+		return errors.New("timoni only supports patches with the build command")
+	}
+
+	if i.hasPostRenderers() && (len(i.HelmArgs) == 0 || i.HelmArgs[0] != "build") {
+		return errors.New("timoni only supports post-renderers with the build command")
+	}
+
+	return nil
+}
+
+func (e *TimoniEngine) Prepare(ctx context.Context, i *Instance) error {
+	return nil
+}
+
+func (e *TimoniEngine) ConstructArgs(i *Instance) []string {
+	args := i.HelmArgs
+
+	if len(i.ValuesToEvaluate) > 0 {
+		args = append(args, "--values", filepath.Join(i.dir, ValuesFile))
+	}
+
+	for _, value := range i.Values {
+		args = append(args, "--values", value)
+	}
+
+	return args
+}
+
+func (e *TimoniEngine) Run(ctx context.Context, i *Instance, inv *Invocation) error {
+	if !i.usesKonduitPostRenderer() {
+		return e.run(ctx, i, inv, i.runOptions()...)
+	}
+
+	// Timoni doesn't support post-renderers, so its output is piped through
+	// the same stages as the Konduit post-renderer after it.
+	timoni := func(ctx context.Context, _ io.Reader, stdout io.Writer) error {
+		return e.run(ctx, i, inv, exec.WithStdout(stdout))
+	}
+
+	return exec.Pipeline(ctx, nil, i.output(), append([]exec.Stage{timoni}, i.postRenderStages()...)...)
+}
+
+func (e *TimoniEngine) run(ctx context.Context, i *Instance, inv *Invocation, opts ...exec.RunOption) (err error) {
+	ctx, span := i.startSpan(ctx, "timoni run", attribute.String("konduit.command", inv.Command))
+	defer func() { tracing.End(span, err) }()
+
+	return i.runner.Run(ctx, inv.Command, inv.Args, opts...)
+}
@@ -0,0 +1,227 @@
+package konduit_test
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/internal/exec"
+	"github.com/jace-ys/konduit/pkg/konduit"
+	"github.com/jace-ys/konduit/pkg/konduit/mocks"
+)
+
+func TestTimoniEngine_New(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		args    []string
+		values  []string
+		opts    []konduit.Option
+		want    *konduit.Instance
+		wantErr string
+	}{
+		{
+			name:   "extracts values from param and args",
+			args:   []string{"apply", "my-app", "oci://my-module", "-f", "args.yaml"},
+			values: []string{"values.cue", "param.yaml"},
+			want: &konduit.Instance{
+				HelmCommand:      konduit.DefaultTimoniCommand,
+				HelmArgs:         []string{"apply", "my-app", "oci://my-module"},
+				Values:           []string{"param.yaml", "args.yaml"},
+				ValuesToEvaluate: []string{"values.cue"},
+			},
+		},
+		{
+			name: "overrides command",
+			args: []string{"build", "my-app", "./module"},
+			opts: []konduit.Option{konduit.WithCommand("./bin/timoni")},
+			want: &konduit.Instance{
+				HelmCommand: "./bin/timoni",
+				HelmArgs:    []string{"build", "my-app", "./module"},
+			},
+		},
+		{
+			name: "allows patches with build command",
+			args: []string{"build", "my-app", "./module"},
+			opts: []konduit.Option{konduit.WithPatches([]string{"patches.cue"})},
+			want: &konduit.Instance{
+				HelmCommand:       konduit.DefaultTimoniCommand,
+				HelmArgs:          []string{"build", "my-app", "./module"},
+				PatchesToEvaluate: []string{"patches.cue"},
+			},
+		},
+		{
+			name:    "rejects patches with other commands",
+			args:    []string{"apply", "my-app", "./module"},
+			opts:    []konduit.Option{konduit.WithPatches([]string{"patches.cue"})},
+			wantErr: "timoni only supports patches with the build command",
+		},
+		{
+			name:    "rejects post-renderers",
+			args:    []string{"build", "my-app", "./module", "--post-renderer", "./bin/renderer"},
+			wantErr: "timoni does not support post-renderers",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tt.opts = append(tt.opts,
+				konduit.WithEngine(konduit.NewTimoniEngine()),
+				konduit.WithEvaluator(konduit.NewCUEEvaluator()),
+			)
+
+			actual, err := konduit.New(tt.args, tt.values, tt.opts...)
+			if tt.wantErr != "" {
+				require.Error(t, err)
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want.HelmCommand, actual.HelmCommand)
+			assert.Equal(t, tt.want.HelmArgs, actual.HelmArgs)
+			assert.Equal(t, tt.want.Values, actual.Values)
+			assert.Equal(t, tt.want.ValuesToEvaluate, actual.ValuesToEvaluate)
+			assert.Equal(t, tt.want.PatchesToEvaluate, actual.PatchesToEvaluate)
+		})
+	}
+}
+
+func TestTimoniEngine_Construct(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		instance *konduit.Instance
+		want     *konduit.Invocation
+	}{
+		{
+			name: "passes through args",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"build", "my-app", "./module"},
+			},
+			want: &konduit.Invocation{
+				Args: []string{"build", "my-app", "./module"},
+			},
+		},
+		{
+			name: "adds evaluated.yaml before static values",
+			instance: &konduit.Instance{
+				HelmArgs:         []string{"apply", "my-app", "./module"},
+				Values:           []string{"values.yaml"},
+				ValuesToEvaluate: []string{"values.cue"},
+			},
+			want: &konduit.Invocation{
+				Args: []string{
+					"apply", "my-app", "./module",
+					"--values", "/tmp/evaluated.yaml",
+					"--values", "values.yaml",
+				},
+			},
+		},
+		{
+			name: "does not add post-renderer for patches",
+			instance: &konduit.Instance{
+				HelmArgs: []string{"build", "my-app", "./module"},
+				Patches:  []string{"patches.yaml"},
+			},
+			want: &konduit.Invocation{
+				Args: []string{"build", "my-app", "./module"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tt.instance.HelmCommand = konduit.DefaultTimoniCommand
+			konduit.WithEngine(konduit.NewTimoniEngine()).Apply(tt.instance)
+			konduit.WithEvaluator(konduit.NewNoopEvaluator()).Apply(tt.instance)
+			konduit.WithWorkDir("/tmp").Apply(tt.instance)
+
+			actual, err := tt.instance.Construct(t.Context())
+			require.NoError(t, err)
+
+			assert.Equal(t, "timoni", actual.Engine)
+			assert.Equal(t, konduit.DefaultTimoniCommand, actual.Command)
+			assert.Equal(t, tt.want.Args, actual.Args)
+		})
+	}
+}
+
+func TestTimoniEngine_Execute(t *testing.T) {
+	t.Parallel()
+
+	instance := &konduit.Instance{
+		HelmCommand: konduit.DefaultTimoniCommand,
+		HelmArgs:    []string{"apply", "my-app", "./module"},
+		Values:      []string{"values.yaml"},
+	}
+
+	runner := mocks.NewMockRunner(t)
+	runner.EXPECT().
+		Run(mock.Anything, konduit.DefaultTimoniCommand, []string{"apply", "my-app", "./module", "--values", "values.yaml"}).
+		Return(nil)
+
+	konduit.WithEngine(konduit.NewTimoniEngine()).Apply(instance)
+	konduit.WithEvaluator(mocks.NewMockEvaluator(t)).Apply(instance)
+	konduit.WithRunner(runner).Apply(instance)
+	konduit.WithWorkDir(t.TempDir()).Apply(instance)
+
+	require.NoError(t, instance.Execute(t.Context()))
+}
+
+func TestTimoniEngine_ExecutePatched(t *testing.T) {
+	t.Parallel()
+
+	instance := &konduit.Instance{
+		HelmCommand: konduit.DefaultTimoniCommand,
+		HelmArgs:    []string{"build", "my-app", "./module"},
+		Patches:     []string{"testdata/patches.yaml"},
+		PostRenderersAfter: []konduit.PostRenderer{
+			{Command: "renderer", Args: []string{"--after"}},
+		},
+	}
+
+	manifests := "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: my-app\n"
+
+	runner := mocks.NewMockRunner(t)
+	runner.EXPECT().
+		Run(mock.Anything, konduit.DefaultTimoniCommand, []string{"build", "my-app", "./module"}, mock.Anything).
+		RunAndReturn(func(ctx context.Context, command string, args []string, opts ...exec.RunOption) error {
+			_, stdout := exec.Streams(opts...)
+			_, err := io.WriteString(stdout, manifests)
+			return err
+		})
+	runner.EXPECT().
+		Run(mock.Anything, "renderer", []string{"--after"}, mock.Anything, mock.Anything).
+		RunAndReturn(func(ctx context.Context, command string, args []string, opts ...exec.RunOption) error {
+			stdin, stdout := exec.Streams(opts...)
+			patched, err := io.ReadAll(stdin)
+			if err != nil {
+				return err
+			}
+			_, err = io.WriteString(stdout, strings.ReplaceAll(string(patched), "my-app", "my-app-after"))
+			return err
+		})
+
+	var stdout bytes.Buffer
+	konduit.WithEngine(konduit.NewTimoniEngine()).Apply(instance)
+	konduit.WithEvaluator(mocks.NewMockEvaluator(t)).Apply(instance)
+	konduit.WithRunner(runner).Apply(instance)
+	konduit.WithStdout(&stdout).Apply(instance)
+	konduit.WithWorkDir(t.TempDir()).Apply(instance)
+
+	require.NoError(t, instance.Execute(t.Context()))
+	assert.Equal(t, "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: patched-my-app-after\n", stdout.String())
+}
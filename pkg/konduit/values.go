@@ -0,0 +1,45 @@
+package konduit
+
+import (
+	"fmt"
+
+	"github.com/goccy/go-yaml"
+)
+
+// MergeValues merges values documents in order of increasing precedence,
+// following Helm's rules for multiple values files: maps are merged
+// recursively, and any other value replaces the previous one. A null replaces
+// the previous value too, rather than deleting the key, so that it still
+// removes the chart default when Helm coalesces the values.
+func MergeValues(docs ...map[string]any) map[string]any {
+	merged := make(map[string]any)
+	for _, doc := range docs {
+		mergeMaps(merged, doc)
+	}
+	return merged
+}
+
+func mergeMaps(dst, src map[string]any) {
+	for key, value := range src {
+		if srcMap, ok := value.(map[string]any); ok {
+			if dstMap, ok := dst[key].(map[string]any); ok {
+				mergeMaps(dstMap, srcMap)
+				continue
+			}
+
+			copied := make(map[string]any, len(srcMap))
+			mergeMaps(copied, srcMap)
+			dst[key] = copied
+			continue
+		}
+		dst[key] = value
+	}
+}
+
+func DecodeValues(data []byte) (map[string]any, error) {
+	values := make(map[string]any)
+	if err := yaml.Unmarshal(data, &values); err != nil {
+		return nil, fmt.Errorf("decode values: %w", err)
+	}
+	return values, nil
+}
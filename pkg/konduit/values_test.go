@@ -0,0 +1,83 @@
+package konduit_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/jace-ys/konduit/pkg/konduit"
+)
+
+func TestMergeValues(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		docs []string
+		want map[string]any
+	}{
+		{
+			name: "returns empty values without documents",
+			want: map[string]any{},
+		},
+		{
+			name: "overrides scalars in order",
+			docs: []string{"replicas: 1\nimage: nginx\n", "replicas: 3\n"},
+			want: map[string]any{"replicas": uint64(3), "image": "nginx"},
+		},
+		{
+			name: "merges maps recursively",
+			docs: []string{
+				"image:\n  repository: nginx\n  tag: \"1.25\"\n",
+				"image:\n  tag: \"1.26\"\n",
+			},
+			want: map[string]any{
+				"image": map[string]any{"repository": "nginx", "tag": "1.26"},
+			},
+		},
+		{
+			name: "replaces lists",
+			docs: []string{"args: [a, b]\n", "args: [c]\n"},
+			want: map[string]any{"args": []any{"c"}},
+		},
+		{
+			name: "replaces maps with scalars",
+			docs: []string{"resources:\n  limits: {}\n", "resources: 1\n"},
+			want: map[string]any{"resources": uint64(1)},
+		},
+		{
+			name: "keeps null values that replace previous ones",
+			docs: []string{
+				"resources:\n  limits: {}\nimage:\n  repository: nginx\n  tag: \"1.25\"\n",
+				"resources: null\nimage:\n  tag: null\n",
+			},
+			want: map[string]any{
+				"resources": nil,
+				"image":     map[string]any{"repository": "nginx", "tag": nil},
+			},
+		},
+		{
+			name: "keeps null values of new maps",
+			docs: []string{"image:\n  repository: nginx\n  tag: null\n"},
+			want: map[string]any{
+				"image": map[string]any{"repository": "nginx", "tag": nil},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			docs := make([]map[string]any, 0, len(tt.docs))
+			for _, doc := range tt.docs {
+				values, err := konduit.DecodeValues([]byte(doc))
+				require.NoError(t, err)
+				docs = append(docs, values)
+			}
+
+			assert.Equal(t, tt.want, konduit.MergeValues(docs...))
+		})
+	}
+}
@@ -0,0 +1,213 @@
+package konduit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jace-ys/konduit/internal/kustomize"
+	"github.com/jace-ys/konduit/internal/workdir"
+)
+
+// ArtifactsFile describes the artifacts in a kept work dir.
+const ArtifactsFile = "artifacts.json"
+
+// workDirFiles are the files written to a work dir by an invocation.
+var workDirFiles = []string{
+	ValuesFile,
+	kustomize.KustomizationFile,
+	kustomize.ManifestsFile,
+	kustomize.OutputFile,
+	ArtifactsFile,
+}
+
+// Artifacts describes the files kept in a work dir, and the invocation that
+// produced them.
+type Artifacts struct {
+	Engine  string     `json:"engine"`
+	Command string     `json:"command"`
+	Args    []string   `json:"args"`
+	Files   []Artifact `json:"files"`
+}
+
+type Artifact struct {
+	Path        string `json:"path"`
+	Description string `json:"description"`
+}
+
+// WorkDir returns the directory that evaluated values, patches and
+// intermediate manifests are written to. It is empty until Execute or Render
+// creates a temporary one, unless set with WithWorkDir.
+func (i *Instance) WorkDir() string {
+	return i.dir
+}
+
+// useWorkDir creates a temporary work dir if none was set, and returns a
+// function that removes it again unless it should be kept. A work dir that was
+// set may be shared with other invocations, so it is locked until the function
+// is called, and the files of any previous invocation are removed from it.
+// Other files are left alone, and the work dir may not contain files that
+// would be overwritten.
+func (i *Instance) useWorkDir(ctx context.Context) (func(), error) {
+	if i.dir != "" {
+		if err := os.MkdirAll(i.dir, 0o755); err != nil {
+			return nil, fmt.Errorf("create work dir: %w", err)
+		}
+
+		unlock, err := workdir.Lock(ctx, i.dir)
+		if err != nil {
+			return nil, fmt.Errorf("lock work dir: %w", err)
+		}
+
+		if err := cleanWorkDir(i.dir); err != nil {
+			unlock()
+			return nil, fmt.Errorf("clean work dir: %w", err)
+		}
+
+		i.sharedDir = true
+		i.log().Debug("using work dir", "dir", i.dir)
+		return unlock, nil
+	}
+
+	dir, err := os.MkdirTemp("", "konduit-*")
+	if err != nil {
+		return nil, fmt.Errorf("create tmp dir: %w", err)
+	}
+	i.dir = dir
+
+	i.log().Debug("using temporary work dir", "dir", dir, "keep", i.keepDir)
+	if i.keepDir {
+		return func() {}, nil
+	}
+
+	return func() { os.RemoveAll(dir) }, nil
+}
+
+// cleanWorkDir removes the files listed in the artifacts file of a previous
+// invocation, and checks that no other file would be overwritten.
+func cleanWorkDir(dir string) error {
+	data, err := os.ReadFile(filepath.Join(dir, ArtifactsFile))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("read artifacts file: %w", err)
+	}
+
+	if err == nil {
+		var previous Artifacts
+		if err := json.Unmarshal(data, &previous); err != nil {
+			return fmt.Errorf("decode artifacts file: %w", err)
+		}
+
+		names := []string{ArtifactsFile}
+		for _, artifact := range previous.Files {
+			if slices.Contains(workDirFiles, artifact.Path) {
+				names = append(names, artifact.Path)
+			}
+		}
+
+		if err := workdir.Remove(dir, names...); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range workDirFiles {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return fmt.Errorf("%s wasn't written by Konduit, use an empty or dedicated work dir", filepath.Join(dir, name))
+		}
+	}
+
+	return nil
+}
+
+// removeIntermediate removes an intermediate file once it has been used,
+// unless the work dir is kept for debugging.
+func (i *Instance) removeIntermediate(filename string) {
+	if !i.keepDir {
+		os.Remove(filename)
+	}
+}
+
+// writeOutput keeps the output of Kustomize if the work dir is kept.
+func (i *Instance) writeOutput(dir string, manifests []byte) error {
+	if !i.keepDir {
+		return nil
+	}
+
+	if _, err := kustomize.WriteOutput(dir, manifests); err != nil {
+		return fmt.Errorf("write kustomize output: %w", err)
+	}
+
+	return nil
+}
+
+// writeArtifacts describes each file left in a kept work dir, so that the
+// steps that produced them can be rerun by hand. It is always written to a
+// work dir that was set, so that the next invocation knows which files to
+// remove.
+func (i *Instance) writeArtifacts(inv *Invocation) error {
+	if !i.keepDir && !i.sharedDir {
+		return nil
+	}
+
+	artifacts := Artifacts{
+		Engine:  inv.Engine,
+		Command: inv.Command,
+		Args:    inv.Args,
+		Files:   make([]Artifact, 0),
+	}
+
+	for _, artifact := range i.artifacts(inv) {
+		if _, err := os.Stat(filepath.Join(i.dir, artifact.Path)); err == nil {
+			artifacts.Files = append(artifacts.Files, artifact)
+		}
+	}
+
+	data, err := json.MarshalIndent(artifacts, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encode artifacts: %w", err)
+	}
+
+	if err := workdir.WriteFile(filepath.Join(i.dir, ArtifactsFile), append(data, '\n')); err != nil {
+		return fmt.Errorf("write artifacts file: %w", err)
+	}
+
+	return nil
+}
+
+func (i *Instance) artifacts(inv *Invocation) []Artifact {
+	rendered := fmt.Sprintf("Manifests rendered by %s", inv.Engine)
+	if len(i.PostRenderersBefore) > 0 {
+		rendered += " and piped through the post-renderers before patches"
+	}
+
+	output := "the final manifests"
+	if after := i.postRenderersAfter(); len(after) > 0 {
+		output = "the input to the post-renderer " + after[0].Command
+	}
+
+	patches := append(append([]string{}, inv.EvaluatedPatches.Files...), inv.Patches...)
+
+	return []Artifact{
+		{
+			Path:        ValuesFile,
+			Description: fmt.Sprintf("Values evaluated from %s, passed to %s with --values.", strings.Join(inv.EvaluatedValues.Files, ", "), inv.Engine),
+		},
+		{
+			Path:        kustomize.KustomizationFile,
+			Description: fmt.Sprintf("Kustomization of the patches from %s. Apply it to %s with: kustomize build %s", strings.Join(patches, ", "), kustomize.ManifestsFile, i.dir),
+		},
+		{
+			Path:        kustomize.ManifestsFile,
+			Description: rendered + ", before the patches are applied.",
+		},
+		{
+			Path:        kustomize.OutputFile,
+			Description: fmt.Sprintf("Manifests after the patches are applied by Kustomize, which are %s.", output),
+		},
+	}
+}
@@ -33,42 +33,71 @@ func TestKonduitCUE(t *testing.T) {
 	})
 	require.NoError(t, err)
 
-	for _, environment := range []string{"development", "production"} {
-		t.Run(environment, func(t *testing.T) {
-			cmd := exec.CommandContext(t.Context(), konduit, "cue",
-				"--cue-base-dir", "../examples",
-				"-v", fmt.Sprintf("../examples/logstash/values/%s/values.cue", environment),
-				"-v", fmt.Sprintf("../examples/logstash/values/%s/values.yaml", environment),
-				"-p", "../examples/logstash/patches/patches.cue",
-				"-p", "../examples/logstash/patches/patches.yaml",
-				"-s", string(secrets),
-				"-s", fmt.Sprintf("@../examples/data/%s.json", environment),
-				"--",
-				"template", "logstash", "../examples/logstash/eck-logstash-0.17.0.tgz",
+	postRenderers := []struct {
+		name  string
+		flags []string
+		args  []string
+	}{
+		{
+			name: "post-renderer",
+			args: []string{
 				"--post-renderer", "../hack/post-render-chain",
 				"--post-renderer-args", "kustomize-1",
 				"--post-renderer-args", "kustomize-2",
-			)
+			},
+		},
+		{
+			name: "post-render-after",
+			flags: []string{
+				"--post-render-after", "../hack/post-render kustomize-1",
+				"--post-render-after", "../hack/post-render kustomize-2",
+			},
+		},
+	}
 
-			var stdout bytes.Buffer
-			var stderr bytes.Buffer
+	for _, environment := range []string{"development", "production"} {
+		for _, postRenderer := range postRenderers {
+			t.Run(environment+"/"+postRenderer.name, func(t *testing.T) {
+				args := []string{
+					"cue",
+					"--cue-base-dir", "../examples",
+					"-v", fmt.Sprintf("../examples/logstash/values/%s/values.cue", environment),
+					"-v", fmt.Sprintf("../examples/logstash/values/%s/values.yaml", environment),
+					"-p", "../examples/logstash/patches/patches.cue",
+					"-p", "../examples/logstash/patches/patches.yaml",
+					"-s", string(secrets),
+					"-s", fmt.Sprintf("@../examples/data/%s.json", environment),
+				}
+				args = append(args, postRenderer.flags...)
+				args = append(args, "--", "template", "logstash", "../examples/logstash/eck-logstash-0.17.0.tgz")
+				args = append(args, postRenderer.args...)
 
-			session, err := gexec.Start(cmd, &stdout, &stderr)
-			require.NoError(t, err)
-			session.Wait(10 * time.Second)
-			require.Equal(t, 0, session.ExitCode(), stderr.String())
+				testKonduitCUE(t, exec.CommandContext(t.Context(), konduit, args...), environment)
+			})
+		}
+	}
+}
 
-			testdata, err := os.ReadFile(fmt.Sprintf("testdata/logstash-%s.yaml", environment))
-			require.NoError(t, err)
+func testKonduitCUE(t *testing.T, cmd *exec.Cmd, environment string) {
+	t.Helper()
 
-			want := decodeYAMLDocs(t, testdata)
-			actual := decodeYAMLDocs(t, stdout.Bytes())
+	var stdout bytes.Buffer
+	var stderr bytes.Buffer
 
-			require.Len(t, actual, len(want))
-			for i := range want {
-				assert.Equal(t, want[i], actual[i])
-			}
-		})
+	session, err := gexec.Start(cmd, &stdout, &stderr)
+	require.NoError(t, err)
+	session.Wait(10 * time.Second)
+	require.Equal(t, 0, session.ExitCode(), stderr.String())
+
+	testdata, err := os.ReadFile(fmt.Sprintf("testdata/logstash-%s.yaml", environment))
+	require.NoError(t, err)
+
+	want := decodeYAMLDocs(t, testdata)
+	actual := decodeYAMLDocs(t, stdout.Bytes())
+
+	require.Len(t, actual, len(want))
+	for i := range want {
+		assert.Equal(t, want[i], actual[i])
 	}
 }
 